@@ -0,0 +1,283 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/health"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/rabbitmq/amqp091-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Server exposes the state of the service to operators. Every endpoint is read only.
+// Events are published straight from the RPC, there is no outbox, so there is
+// no outbox backlog to report either.
+type Server struct {
+	Config  *config.Store
+	Monitor *health.Monitor
+
+	// Conn and Channel are reported as open or closed. Optional.
+	Conn    *amqp091.Connection
+	Channel *amqp091.Channel
+}
+
+type exchange struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Durable bool   `json:"durable"`
+}
+
+type queue struct {
+	Name    string `json:"name"`
+	Durable bool   `json:"durable"`
+}
+
+type binding struct {
+	Exchange string `json:"exchange"`
+	Queue    string `json:"queue"`
+	Key      string `json:"key"`
+}
+
+type topology struct {
+	Exchanges []exchange `json:"exchanges"`
+	Queues    []queue    `json:"queues"`
+	Bindings  []binding  `json:"bindings"`
+}
+
+type routeEntry struct {
+	Method     string `json:"method"`
+	Route      string `json:"route"`
+	Enabled    bool   `json:"enabled"`
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+	Service    string `json:"health_service"`
+}
+
+type brokerState struct {
+	Connection        string `json:"connection"`
+	Channel           string `json:"channel"`
+	PublisherConfirms bool   `json:"publisher_confirms"`
+	health.State
+}
+
+// Handler returns the admin endpoints. /debug/pprof/ is only served while
+// admin.pprof is enabled.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /config", s.config)
+	mux.HandleFunc("GET /topology", s.topology)
+	mux.HandleFunc("GET /broker", s.broker)
+	mux.HandleFunc("GET /routes", s.routes)
+
+	mux.HandleFunc("/debug/pprof/", s.pprof(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", s.pprof(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", s.pprof(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", s.pprof(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", s.pprof(pprof.Trace))
+
+	return s.authorize(mux)
+}
+
+// Serve exposes the admin endpoints on addr until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("serving admin endpoints", "address", addr)
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// authorize requires the admin token as a bearer token once one is configured.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := s.Config.Load().Admin.Token
+
+		if token != "" {
+			sent, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			if !ok || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) pprof(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if admin := s.Config.Load().Admin; !admin.Pprof || admin.Token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func (s *Server) config(w http.ResponseWriter, r *http.Request) {
+	// Going through YAML keeps the configuration file's field names and durations
+	data, err := yaml.Marshal(config.Redacted(s.Config.Load()))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var out map[string]any
+
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) topology(w http.ResponseWriter, r *http.Request) {
+	routes := s.Config.Load().Routes.All()
+
+	out := topology{Exchanges: []exchange{}, Queues: []queue{}, Bindings: []binding{}}
+	exchanges := map[string]bool{}
+	queues := map[string]bool{}
+
+	for _, name := range sortedKeys(routes) {
+		route := routes[name]
+
+		if !exchanges[route.Exchange] {
+			exchanges[route.Exchange] = true
+			out.Exchanges = append(out.Exchanges, exchange{Name: route.Exchange, Type: route.ExchangeType, Durable: route.Durable})
+		}
+
+		if route.Queue == "" {
+			continue
+		}
+
+		// Sharded routes declare a queue per shard instead of their own
+		declared := []config.Route{*route}
+
+		if route.Shards > 0 {
+			declared = nil
+
+			for n := range route.Shards {
+				declared = append(declared, route.Shard(n))
+			}
+		}
+
+		for _, r := range declared {
+			if !queues[r.Queue] {
+				queues[r.Queue] = true
+				out.Queues = append(out.Queues, queue{Name: r.Queue, Durable: true})
+			}
+
+			out.Bindings = append(out.Bindings, binding{Exchange: r.Exchange, Queue: r.Queue, Key: r.Binding()})
+		}
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) broker(w http.ResponseWriter, r *http.Request) {
+	out := brokerState{
+		Connection:        openState(s.Conn != nil, s.Conn != nil && s.Conn.IsClosed()),
+		Channel:           openState(s.Channel != nil, s.Channel != nil && s.Channel.IsClosed()),
+		PublisherConfirms: s.Config.Load().Broker.PublisherConfirms,
+	}
+
+	if s.Monitor != nil {
+		out.State = s.Monitor.State()
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) routes(w http.ResponseWriter, r *http.Request) {
+	routes := s.Config.Load().Routes.All()
+
+	services := map[string]string{}
+
+	for service, names := range health.Services {
+		for _, name := range names {
+			services[name] = service
+		}
+	}
+
+	out := []routeEntry{}
+
+	for _, method := range sortedKeys(producers.MethodRoutes) {
+		for _, name := range producers.MethodRoutes[method] {
+			route, ok := routes[name]
+
+			if !ok {
+				continue
+			}
+
+			out = append(out, routeEntry{
+				Method:     method,
+				Route:      name,
+				Enabled:    route.Enabled,
+				Exchange:   route.Exchange,
+				RoutingKey: route.RoutingKey,
+				Service:    services[name],
+			})
+		}
+	}
+
+	writeJSON(w, out)
+}
+
+func openState(known, closed bool) string {
+	switch {
+	case !known:
+		return "unknown"
+	case closed:
+		return "closed"
+	default:
+		return "open"
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		slog.Warn("failed to write admin response", "error", err)
+	}
+}
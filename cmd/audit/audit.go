@@ -0,0 +1,314 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/auth"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/logging"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"gorm.io/gorm"
+)
+
+const (
+	filePrefix = "audit-"
+	fileSuffix = ".jsonl"
+
+	// fileTimeFormat sorts lexically and is safe in file names.
+	fileTimeFormat = "2006-01-02T15-04-05.000000"
+)
+
+// Outcomes of a publish as recorded in the audit log.
+const (
+	OutcomeConfirmed = "confirmed" // the broker acknowledged the message
+	OutcomeSent      = "sent"      // handed to the broker, publisher confirms are off
+	OutcomeNacked    = "nacked"
+	OutcomeRejected  = "rejected" // never sent: route disabled, flow control or the caller gave up
+	OutcomeFailed    = "failed"
+)
+
+// Record is one publish. Payload is the body as sent unless the route carries
+// customer data, in which case PII is masked and Redacted is set; PayloadSHA256
+// always covers the exact body that was sent.
+type Record struct {
+	ID            uint              `json:"-" gorm:"primaryKey"`
+	PublishedAt   time.Time         `json:"published_at" gorm:"index"`
+	CompletedAt   time.Time         `json:"completed_at"`
+	MessageID     string            `json:"message_id" gorm:"index"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Key           string            `json:"key,omitempty"`
+	Version       int64             `json:"version,omitempty"`
+	Sequence      int64             `json:"sequence,omitempty"`
+	Route         string            `json:"route" gorm:"index"`
+	Exchange      string            `json:"exchange"`
+	RoutingKey    string            `json:"routing_key"`
+	Caller        string            `json:"caller"`
+	RequestID     string            `json:"request_id,omitempty"`
+	PayloadSHA256 string            `json:"payload_sha256"`
+	Payload       json.RawMessage   `json:"payload,omitempty"`
+	Redacted      bool              `json:"redacted,omitempty"`
+	Replay        bool              `json:"replay,omitempty"` // re-published from an earlier record
+	Outcome       string            `json:"outcome"`
+	Sinks         map[string]string `json:"sinks,omitempty" gorm:"serializer:json"` // outcome per backend when publishing to several
+	Error         string            `json:"error,omitempty"`
+}
+
+func (Record) TableName() string {
+	return "audit_records"
+}
+
+// Outcome classifies the result of a publish.
+func Outcome(err error, confirms bool) string {
+	switch {
+	case err == nil && confirms:
+		return OutcomeConfirmed
+	case err == nil:
+		return OutcomeSent
+	case errors.Is(err, rpcerrors.ErrNacked):
+		return OutcomeNacked
+	case errors.Is(err, rpcerrors.ErrRouteDisabled), errors.Is(err, rpcerrors.ErrBackpressure),
+		errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeRejected
+	default:
+		return OutcomeFailed
+	}
+}
+
+// Log appends records to rotating JSON lines files and, optionally, the
+// audit_records table. Files are only ever appended to; old ones are removed
+// once they fall out of the retention period.
+type Log struct {
+	Config *config.Store
+	DB     *gorm.DB
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// Open prepares the audit directory and table. db may be nil when records only
+// go to files.
+func Open(store *config.Store, db *gorm.DB) (*Log, error) {
+	cfg := store.Load().Audit
+
+	if cfg.Directory != "" {
+		if err := os.MkdirAll(cfg.Directory, 0o700); err != nil {
+			return nil, fmt.Errorf("creating audit directory: %w", err)
+		}
+	}
+
+	if cfg.Database {
+		if db == nil {
+			return nil, errors.New("audit.database is set but no database is open")
+		}
+
+		if err := db.AutoMigrate(&Record{}); err != nil {
+			return nil, fmt.Errorf("migrating audit table: %w", err)
+		}
+	}
+
+	l := &Log{Config: store}
+
+	if cfg.Database {
+		l.DB = db
+	}
+
+	return l, nil
+}
+
+// Record completes r with the caller, request ID and payload details and
+// appends it. Failures are logged; the message has already been published.
+func (l *Log) Record(ctx context.Context, r Record, body []byte) {
+	cfg := l.Config.Load().Audit
+
+	sum := sha256.Sum256(body)
+
+	r.PayloadSHA256 = hex.EncodeToString(sum[:])
+	r.Caller = auth.FromContext(ctx).String()
+	r.RequestID = logging.RequestID(ctx)
+
+	if json.Valid(body) {
+		r.Payload = body
+
+		if slices.Contains(cfg.RedactRoutes, r.Route) {
+			r.Payload, r.Redacted = logging.RedactJSON(body)
+		}
+	}
+
+	if err := l.write(cfg, r); err != nil {
+		slog.ErrorContext(ctx, "failed to write audit record", "message_id", r.MessageID, "route", r.Route, "error", err)
+	}
+}
+
+func (l *Log) write(cfg config.AuditConfig, r Record) error {
+	var errs []error
+
+	if cfg.Directory != "" {
+		line, err := json.Marshal(r)
+
+		if err == nil {
+			err = l.append(cfg, append(line, '\n'))
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if l.DB != nil {
+		if err := l.DB.Create(&r).Error; err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (l *Log) append(cfg config.AuditConfig, line []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	if l.file != nil && (l.size+int64(len(line)) > int64(cfg.MaxSizeMB)<<20 || !sameDay(l.opened, now)) {
+		l.file.Close()
+		l.file = nil
+	}
+
+	if l.file == nil {
+		name := filepath.Join(cfg.Directory, filePrefix+now.Format(fileTimeFormat)+fileSuffix)
+
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+
+		if err != nil {
+			return fmt.Errorf("opening audit file: %w", err)
+		}
+
+		l.file, l.size, l.opened = f, 0, now
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+
+	return err
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+
+	return ay == by && am == bm && ad == bd
+}
+
+// Prune removes files and rows older than the retention period.
+func (l *Log) Prune() error {
+	cfg := l.Config.Load().Audit
+	cutoff := time.Now().Add(-cfg.Retention)
+
+	var errs []error
+
+	if cfg.Directory != "" {
+		files, err := Files(cfg.Directory)
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		l.mu.Lock()
+		current := ""
+
+		if l.file != nil {
+			current = l.file.Name()
+		}
+
+		l.mu.Unlock()
+
+		for _, name := range files {
+			info, err := os.Stat(name)
+
+			if err != nil || name == current || !info.ModTime().Before(cutoff) {
+				continue
+			}
+
+			if err := os.Remove(name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if l.DB != nil {
+		if err := l.DB.Where("published_at < ?", cutoff).Delete(&Record{}).Error; err != nil {
+			errs = append(errs, fmt.Errorf("pruning audit table: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run prunes expired records hourly until ctx is cancelled.
+func (l *Log) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if err := l.Prune(); err != nil {
+			slog.Warn("failed to prune audit log", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the current file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+
+	return err
+}
+
+// Files returns the audit files in dir, oldest first.
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, fmt.Errorf("reading audit directory: %w", err)
+	}
+
+	var files []string
+
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), filePrefix) && strings.HasSuffix(e.Name(), fileSuffix) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
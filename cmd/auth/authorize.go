@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	APIKeyHeader        = "x-api-key"
+	AuthorizationHeader = "authorization"
+)
+
+var errNoCredentials = errors.New("no credentials")
+
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// Authorizer authenticates callers by API key or bearer token, falling back to the
+// mutual TLS identity, and checks the call against the configured policy.
+type Authorizer struct {
+	Config *config.Store
+
+	mu   sync.Mutex
+	jwks *keySet
+}
+
+// keySet caches a JWKS file until it changes on disk.
+type keySet struct {
+	path    string
+	modTime time.Time
+	size    int64
+	keys    *jose.JSONWebKeySet
+}
+
+func NewAuthorizer(store *config.Store) *Authorizer {
+	return &Authorizer{Config: store}
+}
+
+// UnaryServerInterceptor resolves the caller identity and rejects calls the policy
+// does not allow. Only the producer service's RPCs are subject to the policy.
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		cfg := a.Config.Load().Auth
+
+		id, err := a.authenticate(ctx, cfg)
+
+		switch {
+		case err == nil:
+			ctx = WithIdentity(ctx, id)
+		case errors.Is(err, errNoCredentials):
+		default:
+			slog.WarnContext(ctx, "rejected credentials", "error", err)
+
+			if cfg.Enforce {
+				return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+			}
+		}
+
+		id = FromContext(ctx)
+
+		logging.Annotate(ctx, slog.String("caller", id.String()))
+
+		method, protected := strings.CutPrefix(info.FullMethod, "/"+rabbitmq_producer.RabbitmqProducerService_ServiceDesc.ServiceName+"/")
+
+		if !protected || Allowed(cfg.Policy, id, method) {
+			return handler(ctx, req)
+		}
+
+		if !cfg.Enforce {
+			slog.WarnContext(ctx, "policy would deny the call, not enforced")
+			return handler(ctx, req)
+		}
+
+		if id.Name == "" {
+			return nil, status.Error(codes.Unauthenticated, "credentials required")
+		}
+
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", id, method)
+	}
+}
+
+// Allowed reports whether the policy lets id call the short method name.
+func Allowed(policy map[string][]string, id Identity, method string) bool {
+	if id.Name == "" {
+		return false
+	}
+
+	for _, caller := range []string{id.Name, "*"} {
+		for _, m := range policy[caller] {
+			if m == "*" || m == method {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// authenticate checks an API key or bearer token sent with the call. Explicit
+// credentials take precedence over the client certificate.
+func (a *Authorizer) authenticate(ctx context.Context, cfg config.AuthConfig) (Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if keys := md.Get(APIKeyHeader); len(keys) > 0 {
+		for caller, key := range cfg.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(keys[0]), []byte(key)) == 1 {
+				return Identity{Name: caller, Method: "apikey"}, nil
+			}
+		}
+
+		return Identity{}, errors.New("unknown api key")
+	}
+
+	if values := md.Get(AuthorizationHeader); len(values) > 0 {
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+
+		if !ok {
+			return Identity{}, errors.New("authorization is not a bearer token")
+		}
+
+		caller, err := a.verify(cfg, strings.TrimSpace(token))
+
+		if err != nil {
+			return Identity{}, err
+		}
+
+		return Identity{Name: caller, Method: "jwt"}, nil
+	}
+
+	return Identity{}, errNoCredentials
+}
+
+// verify checks the token signature against the JWKS file and returns the caller
+// name from the configured claim.
+func (a *Authorizer) verify(cfg config.AuthConfig, token string) (string, error) {
+	if cfg.JWKSFile == "" {
+		return "", errors.New("bearer tokens are not accepted, auth.jwks_file is not set")
+	}
+
+	keys, err := a.keys(cfg.JWKSFile)
+
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms)
+
+	if err != nil {
+		return "", fmt.Errorf("parsing token: %w", err)
+	}
+
+	var claims jwt.Claims
+	var extra map[string]any
+
+	if err := parsed.Claims(keys, &claims, &extra); err != nil {
+		return "", fmt.Errorf("verifying token: %w", err)
+	}
+
+	if claims.Expiry == nil {
+		return "", errors.New("token has no expiry")
+	}
+
+	expected := jwt.Expected{Issuer: cfg.Issuer, Time: time.Now()}
+
+	if cfg.Audience != "" {
+		expected.AnyAudience = jwt.Audience{cfg.Audience}
+	}
+
+	if err := claims.ValidateWithLeeway(expected, jwt.DefaultLeeway); err != nil {
+		return "", err
+	}
+
+	caller, _ := extra[cfg.CallerClaim].(string)
+
+	if caller == "" {
+		return "", fmt.Errorf("token has no %q claim", cfg.CallerClaim)
+	}
+
+	return caller, nil
+}
+
+// keys returns the JWKS at path, reading it again whenever the file changes so
+// keys can be rotated without a restart.
+func (a *Authorizer) keys(path string) (*jose.JSONWebKeySet, error) {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("reading jwks: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if c := a.jwks; c != nil && c.path == path && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.keys, nil
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("reading jwks: %w", err)
+	}
+
+	var keys jose.JSONWebKeySet
+
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parsing jwks %s: %w", path, err)
+	}
+
+	a.jwks = &keySet{path: path, modTime: info.ModTime(), size: info.Size(), keys: &keys}
+
+	return &keys, nil
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity is the authenticated caller of an RPC.
+type Identity struct {
+	Name   string // e.g. the certificate's SPIFFE ID, DNS name or common name
+	Method string // how the caller was authenticated: "mtls", "apikey" or "jwt"
+}
+
+func (i Identity) String() string {
+	if i.Name == "" {
+		return "anonymous"
+	}
+
+	return i.Method + ":" + i.Name
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the caller identity, or the anonymous identity when none was established.
+func FromContext(ctx context.Context) Identity {
+	id, _ := ctx.Value(identityKey{}).(Identity)
+	return id
+}
+
+// PeerIdentity extracts the identity from a verified client certificate, if the
+// connection used mutual TLS.
+func PeerIdentity(ctx context.Context) (Identity, bool) {
+	p, ok := peer.FromContext(ctx)
+
+	if !ok {
+		return Identity{}, false
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return Identity{}, false
+	}
+
+	name := certificateName(tlsInfo.State.VerifiedChains[0][0])
+
+	if name == "" {
+		return Identity{}, false
+	}
+
+	return Identity{Name: name, Method: "mtls"}, true
+}
+
+// certificateName prefers a URI SAN (SPIFFE style), then a DNS SAN, then the common name.
+func certificateName(cert *x509.Certificate) string {
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+
+	return cert.Subject.CommonName
+}
+
+// UnaryIdentityInterceptor records the client certificate identity on the request
+// context so the Authorizer, handlers and logs can attribute the call.
+func UnaryIdentityInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if id, ok := PeerIdentity(ctx); ok {
+			ctx = WithIdentity(ctx, id)
+		}
+
+		return handler(ctx, req)
+	}
+}
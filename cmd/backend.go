@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/health"
+	"github.com/nats-io/nats.go"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// backend is a connected broker, ready to publish. Only the fields of that
+// broker are set.
+type backend struct {
+	Publisher broker.Publisher
+	Flow      *broker.FlowControl
+
+	conn    *amqp091.Connection
+	channel *amqp091.Channel
+	nc      *nats.Conn
+	kafka   *broker.Kafka
+	file    *broker.File
+
+	// secondaries are the publisher.secondaries of the primary backend
+	secondaries []*backend
+}
+
+// openBackend connects publisher.backend and publisher.secondaries. With
+// secondaries, the returned Publisher publishes as publisher.mode says.
+func openBackend(store *config.Store) (*backend, error) {
+	cfg := store.Load()
+
+	primary, err := openSink(cfg.Publisher.Backend, store)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Publisher.Secondaries) == 0 {
+		return primary, nil
+	}
+
+	multi := &broker.Multi{Config: store, Primary: broker.Sink{Name: cfg.Publisher.Backend, Publisher: primary.Publisher}}
+
+	for _, name := range cfg.Publisher.Secondaries {
+		secondary, err := openSink(name, store)
+
+		if err != nil {
+			primary.Close()
+			return nil, fmt.Errorf("connecting secondary %s: %w", name, err)
+		}
+
+		primary.secondaries = append(primary.secondaries, secondary)
+		multi.Secondaries = append(multi.Secondaries, broker.Sink{Name: name, Publisher: secondary.Publisher})
+	}
+
+	primary.Publisher = multi
+
+	return primary, nil
+}
+
+func openSink(name string, store *config.Store) (*backend, error) {
+	cfg := store.Load()
+
+	switch name {
+	case "file":
+		file := broker.NewFile(store)
+
+		return &backend{Publisher: file, file: file}, nil
+	case "kafka":
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Kafka.DeliveryTimeout)
+		defer cancel()
+
+		kafka, err := broker.NewKafka(ctx, store)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &backend{Publisher: kafka, kafka: kafka}, nil
+	case "nats":
+		nc, err := broker.ConnectNATS(cfg.NATS)
+
+		if err != nil {
+			return nil, err
+		}
+
+		js, err := broker.NewJetStream(nc, store)
+
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+
+		return &backend{Publisher: js, nc: nc}, nil
+	}
+
+	conn, err := connectRabbitMQ(cfg.Broker)
+
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := conn.Channel()
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if cfg.Broker.PublisherConfirms {
+		if err := channel.Confirm(false); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	broker.WatchReturns(channel)
+
+	return &backend{
+		Publisher: broker.NewRabbitMQ(channel),
+		Flow:      broker.WatchFlowControl(conn),
+		conn:      conn,
+		channel:   channel,
+	}, nil
+}
+
+// watch reports the broker to monitor once it can no longer publish. The Kafka
+// client reconnects on its own, so only failed deliveries count against it.
+func (b *backend) watch(monitor *health.Monitor) {
+	if b.kafka != nil || b.file != nil {
+		return
+	}
+
+	if b.nc != nil {
+		b.nc.SetClosedHandler(func(*nats.Conn) {
+			monitor.BrokerDown(errors.New("nats connection closed"))
+		})
+
+		return
+	}
+
+	monitor.Blocked = b.Flow.Blocked
+	monitor.WatchBroker(b.conn, b.channel)
+}
+
+// rabbitmq returns the RabbitMQ connection and channel when RabbitMQ is one of
+// the backends.
+func (b *backend) rabbitmq() (*amqp091.Connection, *amqp091.Channel) {
+	for _, sink := range append([]*backend{b}, b.secondaries...) {
+		if sink.conn != nil {
+			return sink.conn, sink.channel
+		}
+	}
+
+	return nil, nil
+}
+
+func (b *backend) Close() {
+	for _, secondary := range b.secondaries {
+		secondary.Close()
+	}
+
+	if b.file != nil {
+		if err := b.file.Close(); err != nil {
+			slog.Warn("failed to close the event files", "error", err)
+		}
+
+		return
+	}
+
+	if b.kafka != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		b.kafka.Close(ctx)
+
+		return
+	}
+
+	if b.nc != nil {
+		// Drain waits for outstanding acks before closing
+		if err := b.nc.Drain(); err != nil {
+			slog.Warn("failed to drain the nats connection", "error", err)
+		}
+
+		return
+	}
+
+	b.channel.Close()
+	b.conn.Close()
+}
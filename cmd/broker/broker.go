@@ -0,0 +1,43 @@
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+)
+
+// Message is an event as handed to a broker, independent of its wire format.
+type Message struct {
+	// Route is the name of the route the message is published on.
+	Route string
+
+	// Key identifies the entity the message is about, such as a payment or a
+	// movie, so brokers that partition keep each entity's messages in order.
+	// Empty when there is no such entity.
+	Key string
+
+	ID            string
+	CorrelationID string
+	ContentType   string
+	Timestamp     time.Time
+	Headers       map[string]any
+	Body          []byte
+}
+
+// Confirmation resolves once the broker has taken responsibility for a message.
+type Confirmation interface {
+	// Wait returns false when the broker refused the message.
+	Wait(ctx context.Context) (bool, error)
+}
+
+// Publisher is everything the producers need from a message broker.
+type Publisher interface {
+	// Declare makes sure the topology the route publishes to exists. It must be
+	// idempotent, it runs before every publish.
+	Declare(ctx context.Context, route config.Route) error
+
+	// Publish sends msg on the route. The confirmation is nil when the broker
+	// does not confirm publishes.
+	Publish(ctx context.Context, route config.Route, msg Message) (Confirmation, error)
+}
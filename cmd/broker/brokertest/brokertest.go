@@ -0,0 +1,320 @@
+// Package brokertest provides an in-memory broker for tests. It follows
+// RabbitMQ's routing semantics closely enough to exercise the producers end to
+// end without external services.
+package brokertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+)
+
+// Delivery is a message as published, with where it was sent.
+type Delivery struct {
+	Exchange   string
+	RoutingKey string
+	broker.Message
+}
+
+// Return is a mandatory publish that no queue was bound for.
+type Return struct {
+	Exchange   string
+	RoutingKey string
+	MessageID  string
+}
+
+type exchange struct {
+	kind    string
+	durable bool
+}
+
+type binding struct {
+	exchange string
+	queue    string
+	key      string
+}
+
+// Broker implements broker.Publisher in memory. Exchanges must be declared
+// before publishing to them and redeclaring one with a different type fails,
+// as with RabbitMQ. Publishes are confirmed unless Confirms is false.
+type Broker struct {
+	// Confirms makes Publish return confirmations. It defaults to true.
+	Confirms bool
+
+	mu        sync.Mutex
+	exchanges map[string]exchange
+	queues    map[string][]broker.Message
+	bindings  []binding
+	published []Delivery
+	returns   []Return
+	nacks     int
+	failures  []error
+}
+
+var _ broker.Publisher = (*Broker)(nil)
+
+func New() *Broker {
+	return &Broker{
+		Confirms:  true,
+		exchanges: map[string]exchange{},
+		queues:    map[string][]broker.Message{},
+	}
+}
+
+// Declare declares the route's exchange, queue and binding.
+func (b *Broker) Declare(ctx context.Context, route config.Route) error {
+	if err := b.DeclareExchange(route.Exchange, route.ExchangeType, route.Durable); err != nil {
+		return err
+	}
+
+	if route.Queue != "" {
+		b.Bind(route.Exchange, route.Queue, route.Binding())
+	}
+
+	return nil
+}
+
+// DeclareExchange declares an exchange of kind direct, topic, fanout or headers.
+func (b *Broker) DeclareExchange(name, kind string, durable bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch kind {
+	case "direct", "topic", "fanout", "headers":
+	default:
+		return fmt.Errorf("exchange declare failed: invalid exchange type %q", kind)
+	}
+
+	if e, ok := b.exchanges[name]; ok && (e.kind != kind || e.durable != durable) {
+		return fmt.Errorf("exchange declare failed: PRECONDITION_FAILED - inequivalent arg for exchange %q", name)
+	}
+
+	b.exchanges[name] = exchange{kind: kind, durable: durable}
+
+	return nil
+}
+
+// Bind declares queue and binds it to exchange with key, the way a consumer would.
+func (b *Broker) Bind(exchange, queue, key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.queues[queue]; !ok {
+		b.queues[queue] = nil
+	}
+
+	for _, existing := range b.bindings {
+		if existing == (binding{exchange, queue, key}) {
+			return
+		}
+	}
+
+	b.bindings = append(b.bindings, binding{exchange, queue, key})
+}
+
+// FailNext makes the next publish fail with err before anything is routed.
+func (b *Broker) FailNext(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = append(b.failures, err)
+}
+
+// NackNext makes the next n publishes be negatively acknowledged. Nacked
+// messages are not routed.
+func (b *Broker) NackNext(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nacks += n
+}
+
+// Publish routes msg to every queue bound to the route's exchange that matches
+// its routing key. Unroutable messages are recorded as returns and still acked.
+func (b *Broker) Publish(ctx context.Context, route config.Route, msg broker.Message) (broker.Confirmation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.failures) > 0 {
+		err := b.failures[0]
+		b.failures = b.failures[1:]
+
+		return nil, err
+	}
+
+	e, ok := b.exchanges[route.Exchange]
+
+	if !ok {
+		return nil, fmt.Errorf("NOT_FOUND - no exchange %q", route.Exchange)
+	}
+
+	if b.nacks > 0 {
+		b.nacks--
+		return b.confirmation(false), nil
+	}
+
+	b.published = append(b.published, Delivery{Exchange: route.Exchange, RoutingKey: route.RoutingKey, Message: msg})
+
+	routed := map[string]bool{}
+
+	for _, bd := range b.bindings {
+		if bd.exchange == route.Exchange && !routed[bd.queue] && matches(e.kind, bd.key, route.RoutingKey) {
+			routed[bd.queue] = true
+			b.queues[bd.queue] = append(b.queues[bd.queue], msg)
+		}
+	}
+
+	if len(routed) == 0 {
+		b.returns = append(b.returns, Return{Exchange: route.Exchange, RoutingKey: route.RoutingKey, MessageID: msg.ID})
+	}
+
+	return b.confirmation(true), nil
+}
+
+func (b *Broker) confirmation(acked bool) broker.Confirmation {
+	if !b.Confirms {
+		return nil
+	}
+
+	return confirmation(acked)
+}
+
+type confirmation bool
+
+func (c confirmation) Wait(ctx context.Context) (bool, error) {
+	return bool(c), nil
+}
+
+// matches applies the exchange type's routing rules. Headers exchanges are not
+// modelled: they route nothing.
+func matches(kind, bindingKey, routingKey string) bool {
+	switch kind {
+	case "fanout":
+		return true
+	case "direct":
+		return bindingKey == routingKey
+	case "topic":
+		return topicMatch(strings.Split(bindingKey, "."), strings.Split(routingKey, "."))
+	default:
+		return false
+	}
+}
+
+// topicMatch matches dot separated words, where * stands for exactly one word
+// and # for zero or more.
+func topicMatch(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatch(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+
+		return false
+	case "*":
+		return len(words) > 0 && topicMatch(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && topicMatch(pattern[1:], words[1:])
+	}
+}
+
+// Messages returns the messages waiting in queue.
+func (b *Broker) Messages(queue string) []broker.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]broker.Message(nil), b.queues[queue]...)
+}
+
+// Published returns every acknowledged publish in order, routed or not.
+func (b *Broker) Published() []Delivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Delivery(nil), b.published...)
+}
+
+// Returns returns the publishes that no queue was bound for.
+func (b *Broker) Returns() []Return {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Return(nil), b.returns...)
+}
+
+// Exchange reports the type of a declared exchange.
+func (b *Broker) Exchange(name string) (kind string, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.exchanges[name]
+
+	return e.kind, ok
+}
+
+// Reset drops all messages and returns, keeping the topology.
+func (b *Broker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for q := range b.queues {
+		b.queues[q] = nil
+	}
+
+	b.published = nil
+	b.returns = nil
+	b.nacks = 0
+	b.failures = nil
+}
+
+// ExpectMessages fails the test unless queue holds exactly n messages, and
+// returns them.
+func (b *Broker) ExpectMessages(t testing.TB, queue string, n int) []broker.Message {
+	t.Helper()
+
+	msgs := b.Messages(queue)
+
+	if len(msgs) != n {
+		t.Fatalf("expected %d messages in %s, got %d", n, queue, len(msgs))
+	}
+
+	return msgs
+}
+
+// ExpectNoReturns fails the test if any publish was unroutable.
+func (b *Broker) ExpectNoReturns(t testing.TB) {
+	t.Helper()
+
+	if r := b.Returns(); len(r) > 0 {
+		t.Fatalf("expected every message to be routed, got returns %+v", r)
+	}
+}
+
+// Decode unmarshals the JSON body of msg into v, failing the test otherwise.
+func Decode(t testing.TB, msg broker.Message, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(msg.Body, v); err != nil {
+		t.Fatalf("decoding message %s: %v", msg.ID, err)
+	}
+}
+
+// Message builds a message with the given body, for publishing directly.
+func Message(body string) broker.Message {
+	return broker.Message{ID: body, ContentType: "text/plain", Body: []byte(body)}
+}
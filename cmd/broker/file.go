@@ -0,0 +1,145 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+)
+
+// Event is a message as File writes it, one JSON object per line.
+type Event struct {
+	Route         string          `json:"route"`
+	Exchange      string          `json:"exchange"`
+	RoutingKey    string          `json:"routing_key"`
+	MessageID     string          `json:"message_id"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Key           string          `json:"key,omitempty"`
+	ContentType   string          `json:"content_type,omitempty"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Headers       map[string]any  `json:"headers,omitempty"`
+	Body          json.RawMessage `json:"body"`
+}
+
+// File writes every message as a JSON line to <file.directory>/<route>.jsonl,
+// or to Stdout when the directory is "-". It lets the service run locally and
+// in CI without a broker. Writes are not confirmed.
+type File struct {
+	Config *config.Store
+
+	// Stdout receives the events when file.directory is "-". Defaults to os.Stdout.
+	Stdout io.Writer
+
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+func NewFile(store *config.Store) *File {
+	return &File{Config: store, files: map[string]*os.File{}}
+}
+
+// Declare creates the event directory.
+func (f *File) Declare(ctx context.Context, route config.Route) error {
+	if dir := f.Config.Load().File.Directory; dir != "-" {
+		return os.MkdirAll(dir, 0o755)
+	}
+
+	return nil
+}
+
+func (f *File) Publish(ctx context.Context, route config.Route, msg Message) (Confirmation, error) {
+	body := json.RawMessage(msg.Body)
+
+	// Bodies that are not JSON are kept as strings
+	if !json.Valid(body) {
+		quoted, err := json.Marshal(string(msg.Body))
+
+		if err != nil {
+			return nil, err
+		}
+
+		body = quoted
+	}
+
+	line, err := json.Marshal(Event{
+		Route:         msg.Route,
+		Exchange:      route.Exchange,
+		RoutingKey:    route.RoutingKey,
+		MessageID:     msg.ID,
+		CorrelationID: msg.CorrelationID,
+		Key:           msg.Key,
+		ContentType:   msg.ContentType,
+		Timestamp:     msg.Timestamp,
+		Headers:       msg.Headers,
+		Body:          body,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out, err := f.writer(msg.Route)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = out.Write(append(line, '\n'))
+
+	return nil, err
+}
+
+// writer returns where route's events go, opening its file on first use.
+func (f *File) writer(route string) (io.Writer, error) {
+	dir := f.Config.Load().File.Directory
+
+	if dir == "-" {
+		if f.Stdout == nil {
+			return os.Stdout, nil
+		}
+
+		return f.Stdout, nil
+	}
+
+	path := filepath.Join(dir, route+".jsonl")
+
+	if file, ok := f.files[path]; ok {
+		return file, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f.files[path] = file
+
+	return file, nil
+}
+
+// Close closes the event files.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var first error
+
+	for path, file := range f.files {
+		if err := file.Close(); err != nil && first == nil {
+			first = err
+		}
+
+		delete(f.files, path)
+	}
+
+	return first
+}
@@ -0,0 +1,225 @@
+package broker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/metrics"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/ordering"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/versions"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ConnectNATS dials the configured NATS servers. The client reconnects on its
+// own for as long as the process runs.
+func ConnectNATS(cfg config.NATSConfig) (*nats.Conn, error) {
+	opts := []nats.Option{
+		nats.Name("rabbitmq_producer_service"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			slog.Warn("disconnected from nats", "error", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			slog.Info("reconnected to nats", "server", nc.ConnectedUrlRedacted())
+			metrics.Reconnects.Inc()
+		}),
+	}
+
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(cfg.CredentialsFile))
+	}
+
+	if cfg.Token != "" {
+		opts = append(opts, nats.Token(cfg.Token))
+	}
+
+	if cfg.TLS != (config.TLSConfig{}) {
+		tlsConfig, err := cfg.TLS.ClientConfig()
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+
+	nc, err := nats.Connect(strings.Join(cfg.URLs, ","), opts...)
+
+	if err != nil {
+		return nil, fmt.Errorf("connecting to nats: %w", err)
+	}
+
+	return nc, nil
+}
+
+// JetStream publishes to JetStream subjects mapped from the routes. Every
+// message carries a Nats-Msg-Id so the stream drops republished events within
+// its duplicate window, and every publish waits for the stream's ack.
+type JetStream struct {
+	Config *config.Store
+
+	js jetstream.JetStream
+
+	mu       sync.Mutex
+	subjects []string // subjects the stream was last declared with
+}
+
+func NewJetStream(nc *nats.Conn, store *config.Store) (*JetStream, error) {
+	js, err := jetstream.New(nc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &JetStream{Config: store, js: js}, nil
+}
+
+// Declare creates or updates nats.stream to capture the subjects of every
+// route. Without a configured stream there is nothing to declare.
+func (j *JetStream) Declare(ctx context.Context, route config.Route) error {
+	cfg := j.Config.Load()
+
+	if cfg.NATS.Stream == "" {
+		return nil
+	}
+
+	var subjects []string
+
+	for name, r := range cfg.Routes.All() {
+		s := cfg.NATS.Subject(name, *r)
+
+		// Sharded routes publish to <subject>.<shard>
+		if _, overridden := cfg.NATS.Subjects[name]; r.Shards > 0 && !overridden {
+			s += ".*"
+		}
+
+		if !slices.Contains(subjects, s) {
+			subjects = append(subjects, s)
+		}
+	}
+
+	sort.Strings(subjects)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if slices.Equal(j.subjects, subjects) {
+		return nil
+	}
+
+	_, err := j.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       cfg.NATS.Stream,
+		Subjects:   subjects,
+		Duplicates: cfg.NATS.DuplicateWindow,
+	})
+
+	if err != nil {
+		return fmt.Errorf("stream declare failed: %w", err)
+	}
+
+	j.subjects = subjects
+
+	return nil
+}
+
+// Publish sends msg to the route's subject.
+func (j *JetStream) Publish(ctx context.Context, route config.Route, msg Message) (Confirmation, error) {
+	cfg := j.Config.Load().NATS
+
+	m := nats.NewMsg(cfg.Subject(msg.Route, route))
+	m.Data = msg.Body
+
+	for key, value := range msg.Headers {
+		m.Header.Set(key, fmt.Sprint(value))
+	}
+
+	if msg.ContentType != "" {
+		m.Header.Set("Content-Type", msg.ContentType)
+	}
+
+	if msg.CorrelationID != "" {
+		m.Header.Set("Correlation-Id", msg.CorrelationID)
+	}
+
+	var opts []jetstream.PublishOpt
+
+	if msg.ID != "" {
+		opts = append(opts, jetstream.WithMsgID(dedupeID(msg)))
+	}
+
+	if cfg.Stream != "" {
+		opts = append(opts, jetstream.WithExpectStream(cfg.Stream))
+	}
+
+	future, err := j.js.PublishMsgAsync(m, opts...)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", rpcerrors.ErrBrokerUnavailable, err)
+	}
+
+	return pubAck{future: future, wait: cfg.AckWait}, nil
+}
+
+// dedupeID identifies the event msg carries. Message IDs alone do not: Strapi
+// events reuse the entry's UID for its create, updates and delete. The route
+// and a digest of the body tell those apart, and the entity version or
+// sequence, when the event has one, tells apart updates that change an entry
+// back to an earlier state.
+func dedupeID(msg Message) string {
+	sum := sha256.Sum256(msg.Body)
+	id := msg.ID + ":" + msg.Route + ":" + hex.EncodeToString(sum[:8])
+
+	for _, header := range []string{versions.Header, ordering.SequenceHeader} {
+		if v, ok := msg.Headers[header]; ok {
+			id += fmt.Sprintf(":%v", v)
+		}
+	}
+
+	return id
+}
+
+type pubAck struct {
+	future jetstream.PubAckFuture
+	wait   time.Duration
+}
+
+// Wait returns false when the stream refused the message, for example because
+// it is full. Duplicates count as accepted, the stream already has the message.
+func (a pubAck) Wait(ctx context.Context) (bool, error) {
+	timer := time.NewTimer(a.wait)
+	defer timer.Stop()
+
+	select {
+	case ack := <-a.future.Ok():
+		if ack.Duplicate {
+			slog.DebugContext(ctx, "stream dropped a duplicate message", "stream", ack.Stream, "sequence", ack.Sequence)
+		}
+
+		return true, nil
+	case err := <-a.future.Err():
+		var apiErr *jetstream.APIError
+
+		if errors.As(err, &apiErr) {
+			slog.WarnContext(ctx, "stream refused the message", "error", err)
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%w: %v", rpcerrors.ErrBrokerUnavailable, err)
+	case <-timer.C:
+		return false, fmt.Errorf("%w: no ack from the stream within %s", rpcerrors.ErrBrokerUnavailable, a.wait)
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
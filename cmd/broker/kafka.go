@@ -0,0 +1,158 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Kafka publishes records to topics mapped from the routes, keyed by the
+// message's entity. Every publish waits for the record's delivery report.
+type Kafka struct {
+	Config *config.Store
+
+	client *kgo.Client
+}
+
+// NewKafka creates the producer client and checks that a broker is reachable.
+func NewKafka(ctx context.Context, store *config.Store) (*Kafka, error) {
+	cfg := store.Load().Kafka
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ClientID(cfg.ClientID),
+		kgo.RecordDeliveryTimeout(cfg.DeliveryTimeout),
+	}
+
+	switch cfg.Acks {
+	case "leader":
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()))
+	case "none":
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()))
+	default:
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	}
+
+	if !cfg.Idempotent {
+		opts = append(opts, kgo.DisableIdempotentWrite())
+	}
+
+	if cfg.AllowAutoTopicCreation {
+		opts = append(opts, kgo.AllowAutoTopicCreation())
+	}
+
+	if cfg.UseTLS {
+		tlsConfig, err := cfg.TLS.ClientConfig()
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+
+	switch cfg.SASLMechanism {
+	case "plain":
+		opts = append(opts, kgo.SASL(plain.Auth{User: cfg.Username, Pass: cfg.Password}.AsMechanism()))
+	case "scram-sha-256":
+		opts = append(opts, kgo.SASL(scram.Auth{User: cfg.Username, Pass: cfg.Password}.AsSha256Mechanism()))
+	case "scram-sha-512":
+		opts = append(opts, kgo.SASL(scram.Auth{User: cfg.Username, Pass: cfg.Password}.AsSha512Mechanism()))
+	}
+
+	client, err := kgo.NewClient(opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to kafka: %w", err)
+	}
+
+	return &Kafka{Config: store, client: client}, nil
+}
+
+// Declare does nothing: topics are created by whoever runs the cluster, or on
+// first use with kafka.allow_auto_topic_creation.
+func (k *Kafka) Declare(ctx context.Context, route config.Route) error {
+	return nil
+}
+
+// Publish produces msg to the route's topic.
+func (k *Kafka) Publish(ctx context.Context, route config.Route, msg Message) (Confirmation, error) {
+	record := &kgo.Record{
+		Topic:     k.Config.Load().Kafka.Topic(msg.Route, route),
+		Value:     msg.Body,
+		Timestamp: msg.Timestamp,
+	}
+
+	if msg.Key != "" {
+		record.Key = []byte(msg.Key)
+	}
+
+	for key, value := range msg.Headers {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: key, Value: []byte(fmt.Sprint(value))})
+	}
+
+	for key, value := range map[string]string{
+		"content-type":   msg.ContentType,
+		"message-id":     msg.ID,
+		"correlation-id": msg.CorrelationID,
+	} {
+		if value != "" {
+			record.Headers = append(record.Headers, kgo.RecordHeader{Key: key, Value: []byte(value)})
+		}
+	}
+
+	report := make(chan error, 1)
+
+	k.client.Produce(ctx, record, func(_ *kgo.Record, err error) {
+		report <- err
+	})
+
+	return delivery(report), nil
+}
+
+// Close flushes buffered records and closes the client.
+func (k *Kafka) Close(ctx context.Context) {
+	if err := k.client.Flush(ctx); err != nil {
+		slog.Warn("failed to flush kafka records", "error", err)
+	}
+
+	k.client.Close()
+}
+
+type delivery chan error
+
+// Wait returns the delivery report. Errors returned by Kafka are kept so they
+// map to a gRPC code; records that could not be delivered in time report the
+// broker unavailable.
+func (d delivery) Wait(ctx context.Context) (bool, error) {
+	select {
+	case err := <-d:
+		var kafkaErr *kerr.Error
+
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			return false, err
+		case errors.As(err, &kafkaErr):
+			return false, fmt.Errorf("delivery failed: %w", err)
+		default:
+			return false, fmt.Errorf("%w: %v", rpcerrors.ErrBrokerUnavailable, err)
+		}
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
@@ -0,0 +1,331 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/metrics"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+)
+
+// MirrorTimeout bounds how long a best-effort publish to a secondary may take
+// in mirror mode, since no caller is waiting for it.
+var MirrorTimeout = 30 * time.Second
+
+// MirrorConcurrency caps the mirror publishes in flight per Multi. Further
+// mirror publishes are dropped and counted rather than queued.
+var MirrorConcurrency = 256
+
+// Sink is a named backend.
+type Sink struct {
+	Name string
+	Publisher
+}
+
+// Multi publishes to a primary backend and, depending on publisher.mode, to
+// secondaries. The primary's result is the publish result except in all mode,
+// where every backend has to accept the message.
+//
+// All mode is at-least-once per backend: the backends are published to side
+// by side, and when one fails the others may have taken the message already,
+// so the caller's retry sends it to them again. The error names the backends
+// that took it.
+type Multi struct {
+	Config      *config.Store
+	Primary     Sink
+	Secondaries []Sink
+
+	once    sync.Once
+	mirrors chan struct{}
+}
+
+func (m *Multi) Declare(ctx context.Context, route config.Route) error {
+	if err := m.Primary.Declare(ctx, route); err != nil {
+		return err
+	}
+
+	mode := m.Config.Load().Publisher.Mode
+
+	if mode != "mirror" && mode != "all" {
+		return nil
+	}
+
+	for _, sink := range m.Secondaries {
+		if err := sink.Declare(ctx, route); err != nil {
+			if mode == "all" {
+				return fmt.Errorf("%s: %w", sink.Name, err)
+			}
+
+			slog.WarnContext(ctx, "declaring the route on a mirror failed", "sink", sink.Name, "exchange", route.Exchange, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Multi) Publish(ctx context.Context, route config.Route, msg Message) (Confirmation, error) {
+	outcomes := SinkOutcomesFrom(ctx)
+
+	switch m.Config.Load().Publisher.Mode {
+	case "mirror":
+		for _, sink := range m.Secondaries {
+			outcomes.set(sink.Name, m.startMirror(ctx, sink, route, msg))
+		}
+	case "all":
+		return nil, m.publishAll(ctx, route, msg)
+	case "shadow":
+		for _, sink := range m.Secondaries {
+			slog.InfoContext(ctx, "shadow publish", "sink", sink.Name, "route", msg.Route, "exchange", route.Exchange,
+				"routing_key", route.RoutingKey, "message_id", msg.ID, "key", msg.Key, "bytes", len(msg.Body))
+
+			metrics.SinkPublishes.WithLabelValues(sink.Name, msg.Route, "shadowed").Inc()
+			outcomes.set(sink.Name, "shadowed")
+		}
+	}
+
+	confirmation, err := publishSink(ctx, m.Primary, route, msg)
+
+	if err != nil {
+		outcomes.set(m.Primary.Name, "failed")
+		return nil, err
+	}
+
+	if confirmation == nil {
+		outcomes.set(m.Primary.Name, "sent")
+		return nil, nil
+	}
+
+	return recordingConfirmation{Confirmation: confirmation, sink: m.Primary.Name, outcomes: outcomes}, nil
+}
+
+// startMirror hands the publish to a secondary to a goroutine unless
+// MirrorConcurrency of them are in flight already, and says which happened.
+func (m *Multi) startMirror(ctx context.Context, sink Sink, route config.Route, msg Message) string {
+	m.once.Do(func() { m.mirrors = make(chan struct{}, MirrorConcurrency) })
+
+	select {
+	case m.mirrors <- struct{}{}:
+	default:
+		metrics.SinkPublishes.WithLabelValues(sink.Name, msg.Route, "dropped").Inc()
+		slog.WarnContext(ctx, "too many mirror publishes in flight, dropping", "sink", sink.Name, "route", msg.Route, "message_id", msg.ID)
+
+		return "dropped"
+	}
+
+	go func() {
+		defer func() { <-m.mirrors }()
+		m.mirror(ctx, sink, route, msg)
+	}()
+
+	return "mirrored"
+}
+
+// mirror publishes to a secondary without holding up the caller. Failures are
+// only logged and counted.
+func (m *Multi) mirror(ctx context.Context, sink Sink, route config.Route, msg Message) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), MirrorTimeout)
+	defer cancel()
+
+	if _, err := deliver(ctx, sink, route, msg); err != nil {
+		slog.WarnContext(ctx, "mirror publish failed", "sink", sink.Name, "route", msg.Route, "message_id", msg.ID, "error", err)
+	}
+}
+
+// publishAll publishes to every backend at once and waits for each to accept
+// or refuse the message.
+func (m *Multi) publishAll(ctx context.Context, route config.Route, msg Message) error {
+	sinks := append([]Sink{m.Primary}, m.Secondaries...)
+	results := make([]string, len(sinks))
+	errs := make([]error, len(sinks))
+
+	var wg sync.WaitGroup
+
+	for i, sink := range sinks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = deliver(ctx, sink, route, msg)
+		}()
+	}
+
+	wg.Wait()
+
+	outcomes := SinkOutcomesFrom(ctx)
+
+	var (
+		accepted []string
+		failures []error
+	)
+
+	for i, sink := range sinks {
+		outcomes.set(sink.Name, results[i])
+
+		if errs[i] != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", sink.Name, errs[i]))
+		} else {
+			accepted = append(accepted, sink.Name)
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	err := errors.Join(failures...)
+
+	if len(accepted) > 0 {
+		return fmt.Errorf("%w (accepted by %s)", err, strings.Join(accepted, ", "))
+	}
+
+	return err
+}
+
+// deliver publishes to sink and waits for its confirmation, returning the outcome.
+func deliver(ctx context.Context, sink Sink, route config.Route, msg Message) (string, error) {
+	confirmation, err := publishSink(ctx, sink, route, msg)
+
+	if err != nil {
+		return "failed", err
+	}
+
+	if confirmation == nil {
+		return "sent", nil
+	}
+
+	acked, err := confirmation.Wait(ctx)
+
+	switch {
+	case err != nil:
+		return "failed", err
+	case !acked:
+		return "nacked", rpcerrors.ErrNacked
+	default:
+		return "confirmed", nil
+	}
+}
+
+// publishSink publishes to sink, counting the outcome once it is known.
+func publishSink(ctx context.Context, sink Sink, route config.Route, msg Message) (Confirmation, error) {
+	start := time.Now()
+
+	confirmation, err := sink.Publish(ctx, route, msg)
+
+	if err != nil {
+		metrics.SinkPublishes.WithLabelValues(sink.Name, msg.Route, "failed").Inc()
+		return nil, err
+	}
+
+	if confirmation == nil {
+		metrics.SinkPublishes.WithLabelValues(sink.Name, msg.Route, "sent").Inc()
+		metrics.SinkDuration.WithLabelValues(sink.Name).Observe(time.Since(start).Seconds())
+
+		return nil, nil
+	}
+
+	return sinkConfirmation{Confirmation: confirmation, sink: sink.Name, route: msg.Route, start: start}, nil
+}
+
+type sinkConfirmation struct {
+	Confirmation
+	sink  string
+	route string
+	start time.Time
+}
+
+func (c sinkConfirmation) Wait(ctx context.Context) (bool, error) {
+	acked, err := c.Confirmation.Wait(ctx)
+
+	outcome := "confirmed"
+
+	switch {
+	case err != nil:
+		outcome = "failed"
+	case !acked:
+		outcome = "nacked"
+	default:
+		metrics.SinkDuration.WithLabelValues(c.sink).Observe(time.Since(c.start).Seconds())
+	}
+
+	metrics.SinkPublishes.WithLabelValues(c.sink, c.route, outcome).Inc()
+
+	return acked, err
+}
+
+// recordingConfirmation reports the primary's confirmation to the outcomes of the publish.
+type recordingConfirmation struct {
+	Confirmation
+	sink     string
+	outcomes *SinkOutcomes
+}
+
+func (c recordingConfirmation) Wait(ctx context.Context) (bool, error) {
+	acked, err := c.Confirmation.Wait(ctx)
+
+	switch {
+	case err != nil:
+		c.outcomes.set(c.sink, "failed")
+	case !acked:
+		c.outcomes.set(c.sink, "nacked")
+	default:
+		c.outcomes.set(c.sink, "confirmed")
+	}
+
+	return acked, err
+}
+
+type sinkOutcomesKey struct{}
+
+// SinkOutcomes collects what became of one message on each backend of a Multi:
+// confirmed, sent, nacked or failed, and for secondaries outside all mode
+// shadowed, mirrored (the result is only counted) or dropped.
+type SinkOutcomes struct {
+	mu       sync.Mutex
+	outcomes map[string]string
+}
+
+// WithSinkOutcomes returns a context in which a Multi reports to the returned SinkOutcomes.
+func WithSinkOutcomes(ctx context.Context) (context.Context, *SinkOutcomes) {
+	outcomes := &SinkOutcomes{}
+	return context.WithValue(ctx, sinkOutcomesKey{}, outcomes), outcomes
+}
+
+// SinkOutcomesFrom returns the SinkOutcomes of ctx, nil if there are none.
+func SinkOutcomesFrom(ctx context.Context) *SinkOutcomes {
+	outcomes, _ := ctx.Value(sinkOutcomesKey{}).(*SinkOutcomes)
+	return outcomes
+}
+
+func (s *SinkOutcomes) set(sink, outcome string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.outcomes == nil {
+		s.outcomes = map[string]string{}
+	}
+
+	s.outcomes[sink] = outcome
+}
+
+// Map returns the outcomes by backend, nil when no Multi reported any.
+func (s *SinkOutcomes) Map() map[string]string {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return maps.Clone(s.outcomes)
+}
@@ -0,0 +1,165 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/metrics"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// RabbitMQ publishes on an AMQP channel. Routes map to exchanges, queues and
+// bindings; publishes are mandatory so unroutable messages are returned.
+type RabbitMQ struct {
+	Channel *amqp091.Channel
+}
+
+func NewRabbitMQ(ch *amqp091.Channel) *RabbitMQ {
+	return &RabbitMQ{Channel: ch}
+}
+
+// Declare makes sure the exchange, and the queue bound to it when the route has
+// one, exist. All declarations are idempotent.
+func (r *RabbitMQ) Declare(ctx context.Context, route config.Route) error {
+	err := r.Channel.ExchangeDeclare(
+		route.Exchange,
+		route.ExchangeType,
+		route.Durable,
+		false, // auto-deleted
+		false, // internal
+		false, // no-wait
+		nil,   // arguments
+	)
+
+	if err != nil {
+		return fmt.Errorf("exchange declare failed: %w", err)
+	}
+
+	if route.Queue == "" {
+		return nil
+	}
+
+	q, err := r.Channel.QueueDeclare(
+		route.Queue,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+
+	if err != nil {
+		return fmt.Errorf("queue declare failed: %w", err)
+	}
+
+	err = r.Channel.QueueBind(
+		q.Name,
+		route.Binding(),
+		route.Exchange,
+		false, // no-wait
+		nil,   // arguments
+	)
+
+	if err != nil {
+		return fmt.Errorf("queue bind failed: %w", err)
+	}
+
+	return nil
+}
+
+// Publish sends msg to the route's exchange with its routing key. Without
+// publisher confirms on the channel there is nothing to wait for.
+func (r *RabbitMQ) Publish(ctx context.Context, route config.Route, msg Message) (Confirmation, error) {
+	confirmation, err := r.Channel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		route.Exchange,
+		route.RoutingKey,
+		true,  // mandatory, unroutable messages come back through WatchReturns
+		false, // immediate
+		amqp091.Publishing{
+			Headers:       amqp091.Table(msg.Headers),
+			ContentType:   msg.ContentType,
+			MessageId:     msg.ID,
+			CorrelationId: msg.CorrelationID,
+			Timestamp:     msg.Timestamp,
+			Body:          msg.Body,
+		},
+	)
+
+	if err != nil || confirmation == nil {
+		return nil, err
+	}
+
+	return deferredConfirmation{confirmation}, nil
+}
+
+type deferredConfirmation struct {
+	*amqp091.DeferredConfirmation
+}
+
+func (c deferredConfirmation) Wait(ctx context.Context) (bool, error) {
+	return c.WaitContext(ctx)
+}
+
+// FlowControl tracks connection.blocked notifications, sent when the broker runs
+// low on memory or disk and stops reading from publishers. Publishing then fails
+// fast instead of hanging until the RPC times out.
+type FlowControl struct {
+	reason atomic.Pointer[string]
+}
+
+// WatchFlowControl follows the blocked state of conn until it is closed.
+func WatchFlowControl(conn *amqp091.Connection) *FlowControl {
+	f := &FlowControl{}
+	notify := conn.NotifyBlocked(make(chan amqp091.Blocking, 1))
+
+	go func() {
+		for b := range notify {
+			if b.Active {
+				slog.Warn("broker blocked publishing", "reason", b.Reason)
+				f.reason.Store(&b.Reason)
+			} else {
+				slog.Info("broker unblocked publishing")
+				f.reason.Store(nil)
+			}
+		}
+	}()
+
+	return f
+}
+
+// Blocked returns the broker's reason while publishing is blocked.
+func (f *FlowControl) Blocked() (string, bool) {
+	if f == nil {
+		return "", false
+	}
+
+	reason := f.reason.Load()
+
+	if reason == nil {
+		return "", false
+	}
+
+	return *reason, true
+}
+
+// WatchReturns counts and logs mandatory publishes the broker could not route to
+// any queue, until ch is closed.
+func WatchReturns(ch *amqp091.Channel) {
+	returns := ch.NotifyReturn(make(chan amqp091.Return, 16))
+
+	go func() {
+		for r := range returns {
+			metrics.PublishReturns.WithLabelValues(r.Exchange, r.RoutingKey).Inc()
+			slog.Warn("broker returned an unroutable message",
+				"exchange", r.Exchange,
+				"routing_key", r.RoutingKey,
+				"message_id", r.MessageId,
+				"reply", r.ReplyText,
+			)
+		}
+	}()
+}
@@ -0,0 +1,1021 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+)
+
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Limits    LimitsConfig    `yaml:"limits"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Admin     AdminConfig     `yaml:"admin"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Audit     AuditConfig     `yaml:"audit"`
+	Versions  VersionsConfig  `yaml:"versions"`
+	Saga      SagaConfig      `yaml:"saga"`
+	Broker    BrokerConfig    `yaml:"broker"`
+	Publisher PublisherConfig `yaml:"publisher"`
+	NATS      NATSConfig      `yaml:"nats"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+	File      FileConfig      `yaml:"file"`
+	Routes    Routes          `yaml:"routes"`
+}
+
+type ServerConfig struct {
+	ListenAddress string          `yaml:"listen_address"`
+	TLS           ServerTLSConfig `yaml:"tls"`
+
+	// DefaultTimeout caps every RPC; Timeouts overrides it per method, keyed by the
+	// short method name such as "Lock_Seats".
+	DefaultTimeout time.Duration            `yaml:"default_timeout" reload:"true"`
+	Timeouts       map[string]time.Duration `yaml:"timeouts" reload:"true"`
+}
+
+type BrokerConfig struct {
+	// URLs are tried in order until one accepts the connection. Use amqps:// for TLS
+	// and keep credentials out of them; set them below or in files instead.
+	URLs []string  `yaml:"urls"`
+	TLS  TLSConfig `yaml:"tls"`
+
+	// AuthMechanism is "plain" (username and password) or "external", which
+	// authenticates with the TLS client certificate.
+	AuthMechanism string `yaml:"auth_mechanism"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password" secret:"true"`
+	UsernameFile  string `yaml:"username_file"`
+	PasswordFile  string `yaml:"password_file"`
+
+	// PublisherConfirms makes every publish wait for the broker's ack, so a
+	// successful RPC means the broker has taken responsibility for the message.
+	PublisherConfirms bool `yaml:"publisher_confirms"`
+
+	ConnectRetries    int           `yaml:"connect_retries"`
+	ConnectRetryDelay time.Duration `yaml:"connect_retry_delay"`
+	Heartbeat         time.Duration `yaml:"heartbeat"`
+}
+
+// PublisherConfig selects the backend the events are published to.
+type PublisherConfig struct {
+	Backend string `yaml:"backend"` // rabbitmq, nats, kafka or file
+
+	// Secondaries also receive the events, as Mode says.
+	Secondaries []string `yaml:"secondaries"`
+
+	// Mode is primary-only (secondaries are connected but unused), mirror
+	// (secondaries are best effort), all (every backend must accept the event,
+	// at least once per backend) or shadow (what secondaries would have been
+	// sent is only logged).
+	Mode string `yaml:"mode" reload:"true"`
+}
+
+// NATSConfig publishes to JetStream. Each route maps to a subject, by default
+// "<subject_prefix>.<exchange>.<routing_key>".
+type NATSConfig struct {
+	URLs            []string  `yaml:"urls"`
+	TLS             TLSConfig `yaml:"tls"` // used for tls:// urls
+	CredentialsFile string    `yaml:"credentials_file"`
+	Token           string    `yaml:"token" secret:"true"`
+
+	// Stream, when set, is created or updated to capture every route's subject.
+	// Leave it empty when streams are managed elsewhere.
+	Stream string `yaml:"stream"`
+
+	// DuplicateWindow is how long the stream remembers Nats-Msg-Id values to
+	// drop duplicate publishes.
+	DuplicateWindow time.Duration `yaml:"duplicate_window"`
+
+	SubjectPrefix string            `yaml:"subject_prefix"`
+	Subjects      map[string]string `yaml:"subjects"` // route name to subject, overriding the mapping
+
+	// AckWait bounds how long a publish waits for the stream's ack.
+	AckWait time.Duration `yaml:"ack_wait"`
+}
+
+// Subject returns the subject route name publishes to.
+func (n NATSConfig) Subject(name string, route Route) string {
+	if subject, ok := n.Subjects[name]; ok {
+		return subject
+	}
+
+	parts := []string{route.Exchange}
+
+	if n.SubjectPrefix != "" {
+		parts = append([]string{n.SubjectPrefix}, parts...)
+	}
+
+	if route.RoutingKey != "" {
+		parts = append(parts, route.RoutingKey)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// KafkaConfig publishes to Kafka. Each route maps to a topic, by default
+// "<topic_prefix>.<exchange>", and records are keyed by the entity they are
+// about so each payment or movie stays on one partition, in order.
+type KafkaConfig struct {
+	Brokers  []string `yaml:"brokers"`
+	ClientID string   `yaml:"client_id"`
+
+	UseTLS bool      `yaml:"use_tls"`
+	TLS    TLSConfig `yaml:"tls"`
+
+	// SASLMechanism is empty, plain, scram-sha-256 or scram-sha-512.
+	SASLMechanism string `yaml:"sasl_mechanism"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password" secret:"true"`
+
+	TopicPrefix string            `yaml:"topic_prefix"`
+	Topics      map[string]string `yaml:"topics"` // route name to topic, overriding the mapping
+
+	// Idempotent makes retries write each record to its partition exactly once
+	// and in order. It needs acks from all in-sync replicas.
+	Idempotent bool `yaml:"idempotent"`
+
+	// Acks is all, leader or none.
+	Acks string `yaml:"acks"`
+
+	// DeliveryTimeout bounds how long a record is retried before its publish fails.
+	DeliveryTimeout time.Duration `yaml:"delivery_timeout"`
+
+	AllowAutoTopicCreation bool `yaml:"allow_auto_topic_creation"`
+}
+
+// Topic returns the topic route name publishes to.
+func (k KafkaConfig) Topic(name string, route Route) string {
+	if topic, ok := k.Topics[name]; ok {
+		return topic
+	}
+
+	if k.TopicPrefix != "" {
+		return k.TopicPrefix + "." + route.Exchange
+	}
+
+	return route.Exchange
+}
+
+// FileConfig writes events as JSON lines instead of publishing them, so the
+// service runs without any broker.
+type FileConfig struct {
+	// Directory gets one <route>.jsonl file per route. With "-" every event is
+	// written to stdout instead.
+	Directory string `yaml:"directory"`
+}
+
+type TLSConfig struct {
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	CAFile     string `yaml:"ca_file"`
+	ServerName string `yaml:"server_name"`
+}
+
+// ServerTLSConfig secures the gRPC listener. The certificate, key and client CA
+// files are watched and reloaded when they change on disk.
+type ServerTLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+
+	// ClientAuth is one of "none", "optional" (verify a certificate when one is
+	// presented) or "require" (mutual TLS).
+	ClientAuth string `yaml:"client_auth"`
+}
+
+func (t ServerTLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// AuthConfig identifies callers by mutual TLS certificate, API key or JWT and
+// decides which RPCs each of them may call.
+type AuthConfig struct {
+	// Enforce rejects unauthenticated callers and calls the policy does not allow.
+	// When off, callers are still identified and denials are only logged. It is
+	// off by default so callers can be given credentials and the policy checked
+	// against the logs before switching it on; without it any caller may still
+	// call every RPC.
+	Enforce bool `yaml:"enforce" reload:"true"`
+
+	// APIKeys maps a caller name to the key it sends in the x-api-key header.
+	APIKeys map[string]string `yaml:"api_keys" secret:"true" reload:"true"`
+
+	// JWKSFile holds the keys bearer tokens are verified against. The caller name
+	// is taken from CallerClaim ("sub" by default).
+	JWKSFile    string `yaml:"jwks_file" reload:"true"`
+	Issuer      string `yaml:"issuer" reload:"true"`
+	Audience    string `yaml:"audience" reload:"true"`
+	CallerClaim string `yaml:"caller_claim" reload:"true"`
+
+	// Policy maps a caller name to the short method names it may call. "*" as a
+	// method allows every RPC; "*" as a caller applies to every authenticated caller.
+	Policy map[string][]string `yaml:"policy" reload:"true"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level" reload:"true"` // debug, info, warn or error
+	Format string `yaml:"format"`              // json or text
+}
+
+type MetricsConfig struct {
+	// ListenAddress serves /metrics over plain HTTP; empty disables the endpoint.
+	ListenAddress string `yaml:"listen_address"`
+}
+
+// AdminConfig serves introspection endpoints for operators on their own port.
+type AdminConfig struct {
+	// ListenAddress for the admin endpoints; empty disables them.
+	ListenAddress string `yaml:"listen_address"`
+
+	// Token, when set, must be sent as "Authorization: Bearer <token>" on every
+	// admin request.
+	Token string `yaml:"token" secret:"true" reload:"true"`
+
+	// Pprof serves /debug/pprof/. It is only enabled together with a token.
+	Pprof bool `yaml:"pprof" reload:"true"`
+}
+
+// DatabaseConfig is the store for the features that persist state through gorm.
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"` // postgres or sqlite; empty means no database
+	DSN    string `yaml:"dsn" secret:"true"`
+}
+
+// AuditConfig controls the append-only record of every publish.
+type AuditConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Directory receives the JSON lines files; empty writes only to the database.
+	Directory string `yaml:"directory"`
+
+	// MaxSizeMB starts a new file once the current one grows past it. Files are
+	// also started daily.
+	MaxSizeMB int `yaml:"max_size_mb"`
+
+	// Retention is how long records are kept in files and in the database.
+	Retention time.Duration `yaml:"retention" reload:"true"`
+
+	// Database also stores records in the audit_records table.
+	Database bool `yaml:"database"`
+
+	// RedactRoutes are the routes whose payloads carry customer data; their
+	// payloads are recorded with PII redacted. The payload hash is always taken
+	// over the body that was sent.
+	RedactRoutes []string `yaml:"redact_routes" reload:"true"`
+}
+
+// VersionsConfig stamps Strapi and payment events with a version per entity,
+// kept in the entity_versions table, so consumers can discard stale events.
+type VersionsConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// SagaConfig tracks bookings from the seat lock to the confirmation mail in the
+// booking_sagas table, releasing the seats when the payment fails or never comes.
+type SagaConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// PaymentTimeout is how long locked seats wait for the payment.
+	PaymentTimeout time.Duration `yaml:"payment_timeout" reload:"true"`
+
+	// RetryInterval is how often timeouts are checked and failed steps retried.
+	RetryInterval time.Duration `yaml:"retry_interval" reload:"true"`
+}
+
+type TracingConfig struct {
+	// Exporter is "none", "otlp" (gRPC to Endpoint) or "stdout", which writes spans
+	// as JSON to standard output or File for offline testing.
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	File        string  `yaml:"file"`
+	SampleRatio float64 `yaml:"sample_ratio"` // for traces not sampled by the caller
+	ServiceName string  `yaml:"service_name"`
+}
+
+// LimitsConfig holds token bucket rate limits written as "<rate>/<s|m|h>" with an
+// optional ":<burst>", e.g. "50/s:100". Without a burst the bucket holds one
+// second of traffic.
+type LimitsConfig struct {
+	// Methods limits an RPC across all callers, keyed by short method name.
+	Methods map[string]string `yaml:"methods" reload:"true"`
+
+	// Callers limits one caller across all RPCs, keyed by identity name. The "*"
+	// entry applies to every caller without an entry of its own, anonymous included.
+	Callers map[string]string `yaml:"callers" reload:"true"`
+}
+
+// Limit is a parsed rate limit.
+type Limit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// ParseLimit parses a limit such as "50/s", "600/m" or "50/s:100".
+func ParseLimit(spec string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(spec), ":")
+	count, unit, ok := strings.Cut(rate, "/")
+
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not <rate>/<unit>", spec)
+	}
+
+	n, err := strconv.ParseFloat(count, 64)
+
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("limit %q needs a positive rate", spec)
+	}
+
+	per, ok := map[string]float64{"s": 1, "m": 60, "h": 3600}[unit]
+
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q: unit must be s, m or h", spec)
+	}
+
+	limit := Limit{PerSecond: n / per, Burst: int(math.Ceil(n / per))}
+
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burst)
+
+		if err != nil || limit.Burst < 1 {
+			return Limit{}, fmt.Errorf("limit %q needs a positive burst", spec)
+		}
+	}
+
+	return limit, nil
+}
+
+// Route describes where one kind of event is published and the topology that is
+// declared for it before publishing. Disabled routes reject publishes.
+type Route struct {
+	Enabled      bool   `yaml:"enabled" reload:"true"`
+	Exchange     string `yaml:"exchange"`
+	ExchangeType string `yaml:"exchange_type"`
+	Durable      bool   `yaml:"durable"` // exchange durability, queues are always durable
+	Queue        string `yaml:"queue"`   // optional, no queue is declared when empty
+	BindingKey   string `yaml:"binding_key"`
+	RoutingKey   string `yaml:"routing_key"`
+
+	// Shards, when set, spreads messages over that many queues by consistent
+	// hashing of their entity, so each entity's messages stay in order on one
+	// queue. Shard n uses <queue>.<n> bound with <binding_key>.<n> and is
+	// published to with <routing_key>.<n>. Routes sharing a queue must use the
+	// same number of shards.
+	Shards int `yaml:"shards"`
+}
+
+// Shard returns the route as used for shard n.
+func (r Route) Shard(n int) Route {
+	suffix := "." + strconv.Itoa(n)
+
+	if r.Queue != "" {
+		r.Queue += suffix
+	}
+
+	if r.BindingKey != "" {
+		r.BindingKey += suffix
+	}
+
+	r.RoutingKey += suffix
+
+	return r
+}
+
+type Routes struct {
+	PaymentSuccess        Route `yaml:"payment_success"`
+	PaymentFailure        Route `yaml:"payment_failure"`
+	LockSeats             Route `yaml:"lock_seats"`
+	UnlockSeats           Route `yaml:"unlock_seats"`
+	SendMail              Route `yaml:"send_mail"`
+	CastCreation          Route `yaml:"cast_creation"`
+	CastDeletion          Route `yaml:"cast_deletion"`
+	MovieTimeSlotCreation Route `yaml:"movie_time_slot_creation"`
+	MovieCreation         Route `yaml:"movie_creation"`
+	MovieDeletion         Route `yaml:"movie_deletion"`
+}
+
+// All returns every route keyed by its configuration name.
+func (r *Routes) All() map[string]*Route {
+	routes := map[string]*Route{}
+
+	v := reflect.ValueOf(r).Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		routes[yamlName(v.Type().Field(i))] = v.Field(i).Addr().Interface().(*Route)
+	}
+
+	return routes
+}
+
+func Default() *Config {
+	strapiRoute := func(key string) Route {
+		return Route{
+			Exchange:     "strapi_create_exchange",
+			ExchangeType: "direct",
+			Durable:      true,
+			Queue:        "strapi_create",
+			RoutingKey:   key,
+		}
+	}
+
+	cfg := &Config{
+		Server: ServerConfig{
+			ListenAddress:  ":1105",
+			DefaultTimeout: 10 * time.Second,
+			Timeouts:       map[string]time.Duration{},
+		},
+		Auth: AuthConfig{
+			CallerClaim: "sub",
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Metrics: MetricsConfig{
+			ListenAddress: ":9105",
+		},
+		Admin: AdminConfig{
+			ListenAddress: "127.0.0.1:9106",
+		},
+		Audit: AuditConfig{
+			Directory:    "audit",
+			MaxSizeMB:    100,
+			Retention:    90 * 24 * time.Hour,
+			RedactRoutes: []string{"payment_success", "payment_failure", "send_mail"},
+		},
+		Saga: SagaConfig{
+			PaymentTimeout: 15 * time.Minute,
+			RetryInterval:  30 * time.Second,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4317",
+			SampleRatio: 1,
+			ServiceName: "rabbitmq_producer_service",
+		},
+		Broker: BrokerConfig{
+			URLs:              []string{"amqp://rabbitmq_booking_app:5672/"},
+			AuthMechanism:     "plain",
+			PublisherConfirms: true,
+			ConnectRetries:    10,
+			ConnectRetryDelay: 3 * time.Second,
+			Heartbeat:         10 * time.Second,
+		},
+		Publisher: PublisherConfig{
+			Backend: "rabbitmq",
+			Mode:    "primary-only",
+		},
+		NATS: NATSConfig{
+			URLs:            []string{"nats://localhost:4222"},
+			DuplicateWindow: 2 * time.Minute,
+			AckWait:         5 * time.Second,
+		},
+		Kafka: KafkaConfig{
+			Brokers:         []string{"localhost:9092"},
+			ClientID:        "rabbitmq_producer_service",
+			Idempotent:      true,
+			Acks:            "all",
+			DeliveryTimeout: 30 * time.Second,
+		},
+		File: FileConfig{
+			Directory: "events",
+		},
+		Routes: Routes{
+			// The payment routes publish with the queue name as routing key, which
+			// existing consumers depend on, and bind the queue with it too so
+			// mandatory publishes are not returned.
+			PaymentSuccess: Route{
+				Exchange:     "payment_success_exchange",
+				ExchangeType: "direct",
+				Durable:      true,
+				Queue:        "payment_service_success",
+				BindingKey:   "payment_service_success",
+				RoutingKey:   "payment_service_success",
+			},
+			PaymentFailure: Route{
+				Exchange:     "payment_failure_exchange",
+				ExchangeType: "direct",
+				Durable:      true,
+				Queue:        "payment_service_failure",
+				BindingKey:   "payment_service_failure",
+				RoutingKey:   "payment_service_failure",
+			},
+			LockSeats: Route{
+				Exchange:     "lock_seats",
+				ExchangeType: "direct",
+				Queue:        "lock_seats_queue",
+				RoutingKey:   "lock_seats_key",
+			},
+			UnlockSeats: Route{
+				Exchange:     "unlock_seats",
+				ExchangeType: "direct",
+				Queue:        "unlock_seats_queue",
+				RoutingKey:   "unlock_seats_key",
+			},
+			SendMail: Route{
+				Exchange:     "send_mail",
+				ExchangeType: "direct",
+				Durable:      true,
+				RoutingKey:   "send_mail_key",
+			},
+			CastCreation:          strapiRoute("cast_creation"),
+			CastDeletion:          strapiRoute("cast_deletion"),
+			MovieTimeSlotCreation: strapiRoute("movie_time_slot_creation"),
+			MovieCreation:         strapiRoute("movie_creation"),
+			MovieDeletion:         strapiRoute("movie_deletion"),
+		},
+	}
+
+	for _, route := range cfg.Routes.All() {
+		route.Enabled = true
+	}
+
+	return cfg
+}
+
+// MethodTimeout returns the cap for the given full gRPC method name.
+func (c *Config) MethodTimeout(fullMethod string) time.Duration {
+	if d, ok := c.Server.Timeouts[shortMethodName(fullMethod)]; ok && d > 0 {
+		return d
+	}
+
+	return c.Server.DefaultTimeout
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.ListenAddress == "" {
+		errs = append(errs, errors.New("server.listen_address is required"))
+	}
+
+	if c.Server.DefaultTimeout <= 0 {
+		errs = append(errs, errors.New("server.default_timeout must be positive"))
+	}
+
+	for name, d := range c.Server.Timeouts {
+		if !knownMethod(name) {
+			errs = append(errs, fmt.Errorf("server.timeouts: unknown method %q", name))
+		}
+
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("server.timeouts.%s must be positive", name))
+		}
+	}
+
+	errs = append(errs, c.Server.TLS.validate()...)
+	errs = append(errs, c.Auth.validate()...)
+
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logging.level %q is not one of debug, info, warn, error", c.Logging.Level))
+	}
+
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		errs = append(errs, fmt.Errorf("logging.format %q is not one of json, text", c.Logging.Format))
+	}
+
+	if c.Admin.Pprof && c.Admin.Token == "" {
+		errs = append(errs, errors.New("admin.pprof needs admin.token"))
+	}
+
+	switch c.Database.Driver {
+	case "":
+	case "postgres", "sqlite":
+		if c.Database.DSN == "" {
+			errs = append(errs, errors.New("database.dsn is required"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database.driver %q is not one of postgres, sqlite", c.Database.Driver))
+	}
+
+	errs = append(errs, c.Audit.validate(c)...)
+
+	if c.Versions.Enabled && c.Database.Driver == "" {
+		errs = append(errs, errors.New("versions.enabled needs database.driver"))
+	}
+
+	if c.Saga.Enabled {
+		if c.Database.Driver == "" {
+			errs = append(errs, errors.New("saga.enabled needs database.driver"))
+		}
+
+		if c.Saga.PaymentTimeout <= 0 || c.Saga.RetryInterval <= 0 {
+			errs = append(errs, errors.New("saga durations must be positive"))
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint is required for the otlp exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q is not one of none, otlp, stdout", c.Tracing.Exporter))
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+
+	for name, spec := range c.Limits.Methods {
+		if !knownMethod(name) {
+			errs = append(errs, fmt.Errorf("limits.methods: unknown method %q", name))
+		}
+
+		if _, err := ParseLimit(spec); err != nil {
+			errs = append(errs, fmt.Errorf("limits.methods.%s: %w", name, err))
+		}
+	}
+
+	for name, spec := range c.Limits.Callers {
+		if _, err := ParseLimit(spec); err != nil {
+			errs = append(errs, fmt.Errorf("limits.callers.%s: %w", name, err))
+		}
+	}
+
+	errs = append(errs, c.Publisher.validate(c)...)
+
+	if len(c.Broker.URLs) == 0 {
+		errs = append(errs, errors.New("broker.urls needs at least one url"))
+	}
+
+	for _, raw := range c.Broker.URLs {
+		u, err := url.Parse(raw)
+
+		if err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("broker.urls: %q is not an amqp:// or amqps:// url", redactURL(raw)))
+			continue
+		}
+
+		if c.Broker.AuthMechanism == "external" && u.Scheme != "amqps" {
+			errs = append(errs, fmt.Errorf("broker.urls: %q must use amqps:// with the external auth mechanism", redactURL(raw)))
+		}
+	}
+
+	switch c.Broker.AuthMechanism {
+	case "plain":
+	case "external":
+		if c.Broker.TLS.CertFile == "" {
+			errs = append(errs, errors.New("broker.auth_mechanism external needs broker.tls.cert_file and key_file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("broker.auth_mechanism %q is not one of plain, external", c.Broker.AuthMechanism))
+	}
+
+	if c.Broker.Username != "" && c.Broker.UsernameFile != "" {
+		errs = append(errs, errors.New("broker.username and broker.username_file are mutually exclusive"))
+	}
+
+	if c.Broker.Password != "" && c.Broker.PasswordFile != "" {
+		errs = append(errs, errors.New("broker.password and broker.password_file are mutually exclusive"))
+	}
+
+	if c.Broker.ConnectRetries < 1 {
+		errs = append(errs, errors.New("broker.connect_retries must be at least 1"))
+	}
+
+	if c.Broker.ConnectRetryDelay < 0 || c.Broker.Heartbeat < 0 {
+		errs = append(errs, errors.New("broker durations must not be negative"))
+	}
+
+	errs = append(errs, c.Broker.TLS.validate("broker.tls")...)
+
+	shards := map[string]int{}
+
+	for name, route := range c.Routes.All() {
+		errs = append(errs, route.validate("routes."+name)...)
+
+		if route.Queue == "" {
+			continue
+		}
+
+		if n, ok := shards[route.Queue]; ok && n != route.Shards {
+			errs = append(errs, fmt.Errorf("routes.%s.shards: every route to queue %s needs the same number of shards", name, route.Queue))
+		}
+
+		shards[route.Queue] = route.Shards
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t ServerTLSConfig) validate() []error {
+	var errs []error
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls: cert_file and key_file must be set together"))
+	}
+
+	switch t.ClientAuth {
+	case "", "none":
+	case "optional", "require":
+		if t.ClientCAFile == "" {
+			errs = append(errs, fmt.Errorf("server.tls.client_auth %q needs client_ca_file", t.ClientAuth))
+		}
+
+		if !t.Enabled() {
+			errs = append(errs, errors.New("server.tls.client_auth needs cert_file and key_file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("server.tls.client_auth %q is not one of none, optional, require", t.ClientAuth))
+	}
+
+	return errs
+}
+
+func (a AuthConfig) validate() []error {
+	var errs []error
+
+	callers := map[string]string{}
+
+	for caller, key := range a.APIKeys {
+		if key == "" {
+			errs = append(errs, fmt.Errorf("auth.api_keys.%s is empty", caller))
+		} else if other, ok := callers[key]; ok {
+			errs = append(errs, fmt.Errorf("auth.api_keys: %s and %s share a key", min(caller, other), max(caller, other)))
+		}
+
+		callers[key] = caller
+	}
+
+	if a.JWKSFile != "" && a.CallerClaim == "" {
+		errs = append(errs, errors.New("auth.caller_claim is required with auth.jwks_file"))
+	}
+
+	for caller, methods := range a.Policy {
+		for _, m := range methods {
+			if m != "*" && !knownMethod(m) {
+				errs = append(errs, fmt.Errorf("auth.policy.%s: unknown method %q", caller, m))
+			}
+		}
+	}
+
+	return errs
+}
+
+func (t TLSConfig) validate(prefix string) []error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return []error{fmt.Errorf("%s: cert_file and key_file must be set together", prefix)}
+	}
+
+	return nil
+}
+
+func (r *Route) validate(prefix string) []error {
+	var errs []error
+
+	if r.Exchange == "" {
+		errs = append(errs, fmt.Errorf("%s.exchange is required", prefix))
+	}
+
+	switch r.ExchangeType {
+	case "direct", "topic", "headers":
+		if r.RoutingKey == "" {
+			errs = append(errs, fmt.Errorf("%s.routing_key is required for %s exchanges", prefix, r.ExchangeType))
+		}
+	case "fanout":
+	default:
+		errs = append(errs, fmt.Errorf("%s.exchange_type %q is not one of direct, topic, fanout, headers", prefix, r.ExchangeType))
+	}
+
+	switch {
+	case r.Shards < 0:
+		errs = append(errs, fmt.Errorf("%s.shards must not be negative", prefix))
+	case r.Shards > 0 && r.ExchangeType != "direct" && r.ExchangeType != "topic":
+		errs = append(errs, fmt.Errorf("%s.shards needs a direct or topic exchange", prefix))
+	}
+
+	return errs
+}
+
+func (p *PublisherConfig) validate(c *Config) []error {
+	var errs []error
+
+	switch p.Mode {
+	case "primary-only", "mirror", "all", "shadow":
+	default:
+		errs = append(errs, fmt.Errorf("publisher.mode %q is not one of primary-only, mirror, all, shadow", p.Mode))
+	}
+
+	seen := map[string]bool{}
+
+	for i, backend := range append([]string{p.Backend}, p.Secondaries...) {
+		key := "publisher.backend"
+
+		if i > 0 {
+			key = fmt.Sprintf("publisher.secondaries[%d]", i-1)
+		}
+
+		if seen[backend] {
+			errs = append(errs, fmt.Errorf("%s: %s is already used", key, backend))
+			continue
+		}
+
+		seen[backend] = true
+
+		switch backend {
+		case "rabbitmq":
+		case "nats":
+			errs = append(errs, c.NATS.validate(c)...)
+		case "kafka":
+			errs = append(errs, c.Kafka.validate(c)...)
+		case "file":
+			if c.File.Directory == "" {
+				errs = append(errs, errors.New("file.directory is required, use - for stdout"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s %q is not one of rabbitmq, nats, kafka, file", key, backend))
+		}
+	}
+
+	return errs
+}
+
+func (n *NATSConfig) validate(c *Config) []error {
+	var errs []error
+
+	if len(n.URLs) == 0 {
+		errs = append(errs, errors.New("nats.urls needs at least one url"))
+	}
+
+	if n.AckWait <= 0 {
+		errs = append(errs, errors.New("nats.ack_wait must be positive"))
+	}
+
+	if n.Stream != "" && n.DuplicateWindow <= 0 {
+		errs = append(errs, errors.New("nats.duplicate_window must be positive"))
+	}
+
+	routes := c.Routes.All()
+
+	for name := range n.Subjects {
+		if _, ok := routes[name]; !ok {
+			errs = append(errs, fmt.Errorf("nats.subjects: unknown route %q", name))
+		}
+	}
+
+	for name, route := range routes {
+		subject := n.Subject(name, *route)
+
+		if strings.ContainsAny(subject, " *>") || strings.Contains(subject, "..") {
+			errs = append(errs, fmt.Errorf("nats: route %s maps to invalid subject %q", name, subject))
+		}
+	}
+
+	return errs
+}
+
+// kafkaTopic matches the names Kafka accepts for topics.
+var kafkaTopic = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+func (k *KafkaConfig) validate(c *Config) []error {
+	var errs []error
+
+	if len(k.Brokers) == 0 {
+		errs = append(errs, errors.New("kafka.brokers needs at least one broker"))
+	}
+
+	switch k.Acks {
+	case "all":
+	case "leader", "none":
+		if k.Idempotent {
+			errs = append(errs, fmt.Errorf("kafka.idempotent needs kafka.acks all, not %q", k.Acks))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("kafka.acks %q is not one of all, leader, none", k.Acks))
+	}
+
+	if k.DeliveryTimeout <= 0 {
+		errs = append(errs, errors.New("kafka.delivery_timeout must be positive"))
+	}
+
+	switch k.SASLMechanism {
+	case "":
+	case "plain", "scram-sha-256", "scram-sha-512":
+		if k.Username == "" {
+			errs = append(errs, fmt.Errorf("kafka.sasl_mechanism %s needs kafka.username", k.SASLMechanism))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("kafka.sasl_mechanism %q is not one of plain, scram-sha-256, scram-sha-512", k.SASLMechanism))
+	}
+
+	routes := c.Routes.All()
+
+	for name := range k.Topics {
+		if _, ok := routes[name]; !ok {
+			errs = append(errs, fmt.Errorf("kafka.topics: unknown route %q", name))
+		}
+	}
+
+	for name, route := range routes {
+		if topic := k.Topic(name, *route); !kafkaTopic.MatchString(topic) || topic == "." || topic == ".." {
+			errs = append(errs, fmt.Errorf("kafka: route %s maps to invalid topic %q", name, topic))
+		}
+	}
+
+	return errs
+}
+
+func (a *AuditConfig) validate(c *Config) []error {
+	if !a.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if a.Directory == "" && !a.Database {
+		errs = append(errs, errors.New("audit needs audit.directory or audit.database"))
+	}
+
+	if a.Database && c.Database.Driver == "" {
+		errs = append(errs, errors.New("audit.database needs database.driver"))
+	}
+
+	if a.MaxSizeMB < 1 {
+		errs = append(errs, errors.New("audit.max_size_mb must be at least 1"))
+	}
+
+	if a.Retention <= 0 {
+		errs = append(errs, errors.New("audit.retention must be positive"))
+	}
+
+	routes := c.Routes.All()
+
+	for _, name := range a.RedactRoutes {
+		if _, ok := routes[name]; !ok {
+			errs = append(errs, fmt.Errorf("audit.redact_routes: unknown route %q", name))
+		}
+	}
+
+	return errs
+}
+
+// Binding returns the key the queue is bound with, which defaults to the routing key.
+func (r Route) Binding() string {
+	if r.BindingKey != "" {
+		return r.BindingKey
+	}
+
+	return r.RoutingKey
+}
+
+func shortMethodName(fullMethod string) string {
+	return strings.TrimPrefix(fullMethod, "/"+rabbitmq_producer.RabbitmqProducerService_ServiceDesc.ServiceName+"/")
+}
+
+func knownMethod(name string) bool {
+	for _, m := range rabbitmq_producer.RabbitmqProducerService_ServiceDesc.Methods {
+		if m.MethodName == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redactURL(raw string) string {
+	// Kafka brokers are host:port addresses, which may still start with credentials
+	if !strings.Contains(raw, "://") {
+		at := strings.LastIndex(raw, "@")
+
+		if at < 0 {
+			return raw
+		}
+
+		user, _, _ := strings.Cut(raw[:at], ":")
+
+		return user + ":xxxxx" + raw[at:]
+	}
+
+	u, err := url.Parse(raw)
+
+	if err != nil {
+		return "<unparseable>"
+	}
+
+	return u.Redacted()
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+
+	return name
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment variable read by Load, for example
+// PRODUCER_SERVER_LISTEN_ADDRESS or PRODUCER_ROUTES_LOCK_SEATS_EXCHANGE.
+const EnvPrefix = "PRODUCER_"
+
+// Load builds the configuration from, in increasing order of precedence, the
+// built-in defaults, the YAML file named by -config or PRODUCER_CONFIG, environment
+// variables and command-line flags. Every setting is reachable from all three
+// sources using its dotted path, e.g. -broker.urls or PRODUCER_BROKER_URLS.
+func Load(args []string) (*Config, string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("producer", flag.ContinueOnError)
+
+	path := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "path to a YAML configuration file")
+
+	overrides := map[string]string{}
+
+	for _, f := range fields(cfg) {
+		fs.Func(f.path, f.usage(), func(s string) error {
+			overrides[f.path] = s
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	if *path != "" {
+		if err := LoadFile(*path, cfg); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, "", err
+	}
+
+	for _, f := range fields(cfg) {
+		if s, ok := overrides[f.path]; ok {
+			if err := set(f.value, s); err != nil {
+				return nil, "", fmt.Errorf("flag -%s: %w", f.path, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, "", fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, *path, nil
+}
+
+// LoadFile decodes the YAML file at path on top of cfg. Unknown keys are rejected so
+// typos do not silently fall back to defaults.
+func LoadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	// An empty file decodes to io.EOF and simply keeps the current values.
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	for _, f := range fields(cfg) {
+		if s, ok := lookup(f.env()); ok {
+			if err := set(f.value, s); err != nil {
+				return fmt.Errorf("env %s: %w", f.env(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// field is one leaf setting of the configuration tree. Leaves inherit the secret
+// and reload tags of the structs they are nested in.
+type field struct {
+	path   string
+	value  reflect.Value
+	secret bool
+	reload bool
+}
+
+func (f field) env() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.path, ".", "_"))
+}
+
+func (f field) usage() string {
+	return fmt.Sprintf("overrides %s (env %s)", f.path, f.env())
+}
+
+// fields walks cfg and returns its leaves sorted by path.
+func fields(cfg *Config) []field {
+	var out []field
+
+	walk(reflect.ValueOf(cfg).Elem(), field{}, &out)
+
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+
+	return out
+}
+
+func walk(v reflect.Value, parent field, out *[]field) {
+	if v.Kind() == reflect.Struct {
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+
+			if !sf.IsExported() {
+				continue
+			}
+
+			child := field{
+				path:   yamlName(sf),
+				value:  v.Field(i),
+				secret: parent.secret || sf.Tag.Get("secret") == "true",
+				reload: parent.reload || sf.Tag.Get("reload") == "true",
+			}
+
+			if parent.path != "" {
+				child.path = parent.path + "." + child.path
+			}
+
+			walk(v.Field(i), child, out)
+		}
+
+		return
+	}
+
+	*out = append(*out, parent)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses s into v. Lists are comma separated and maps use key=value pairs.
+func set(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		parts := splitList(s)
+
+		v.Set(reflect.ValueOf(parts).Convert(v.Type()))
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		m := reflect.MakeMap(v.Type())
+
+		for _, pair := range splitList(s) {
+			key, raw, ok := strings.Cut(pair, "=")
+
+			if !ok {
+				return fmt.Errorf("%q is not a key=value pair", pair)
+			}
+
+			elem := reflect.New(v.Type().Elem()).Elem()
+
+			// Lists inside a map are separated by "|" since "," separates the pairs
+			if elem.Kind() == reflect.Slice {
+				raw = strings.ReplaceAll(raw, "|", ",")
+			}
+
+			if err := set(elem, raw); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), elem)
+		}
+
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+
+	return nil
+}
+
+func splitList(s string) []string {
+	var parts []string
+
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	return parts
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+const redacted = "[REDACTED]"
+
+// Store holds the live configuration. Readers always get a complete snapshot and
+// must treat it as read-only; Reload swaps in a new snapshot atomically.
+type Store struct {
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	subscribers []func(previous, next *Config)
+}
+
+func NewStore(cfg *Config) *Store {
+	s := &Store{}
+	s.current.Store(cfg)
+	return s
+}
+
+// Load returns the current snapshot. A nil store yields the defaults, which keeps
+// zero-value producers usable in tests and tools.
+func (s *Store) Load() *Config {
+	if s == nil {
+		return Default()
+	}
+
+	return s.current.Load()
+}
+
+// Subscribe registers fn to be called after every reload that changed something.
+func (s *Store) Subscribe(fn func(previous, next *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Change is a single setting that differs between two configurations.
+type Change struct {
+	Path    string
+	Old     string
+	New     string
+	Applied bool // false when the setting needs a restart to take effect
+}
+
+func (c Change) String() string {
+	if c.Applied {
+		return fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+	}
+
+	return fmt.Sprintf("%s: %s -> %s (requires restart, ignored)", c.Path, c.Old, c.New)
+}
+
+// Reload applies the reloadable settings of next on top of the current snapshot
+// and reports every difference. next must already be validated.
+func (s *Store) Reload(next *Config) []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.current.Load()
+	merged := Clone(previous)
+
+	changes := Diff(previous, next)
+
+	mergedFields := fields(merged)
+	nextFields := fields(next)
+
+	for i, f := range mergedFields {
+		if f.reload {
+			f.value.Set(deepCopy(nextFields[i].value))
+		}
+	}
+
+	applied := false
+
+	for _, c := range changes {
+		applied = applied || c.Applied
+	}
+
+	if !applied {
+		return changes
+	}
+
+	s.current.Store(merged)
+
+	for _, fn := range s.subscribers {
+		fn(previous, merged)
+	}
+
+	return changes
+}
+
+// Diff lists the settings that differ between a and b. Secrets are never printed.
+func Diff(a, b *Config) []Change {
+	var changes []Change
+
+	aFields := fields(a)
+	bFields := fields(b)
+
+	for i, f := range aFields {
+		if equal(f.value, bFields[i].value) {
+			continue
+		}
+
+		change := Change{
+			Path:    f.path,
+			Old:     f.display(),
+			New:     bFields[i].display(),
+			Applied: f.reload,
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// Clone returns a deep copy of cfg.
+func Clone(cfg *Config) *Config {
+	out := &Config{}
+
+	outFields := fields(out)
+
+	for i, f := range fields(cfg) {
+		outFields[i].value.Set(deepCopy(f.value))
+	}
+
+	return out
+}
+
+// Redacted returns a copy of cfg that is safe to show: secrets are replaced and
+// credentials are removed from broker urls.
+func Redacted(cfg *Config) *Config {
+	out := Clone(cfg)
+
+	for _, f := range fields(out) {
+		if !f.secret || f.value.IsZero() {
+			continue
+		}
+
+		switch f.value.Kind() {
+		case reflect.String:
+			f.value.SetString(redacted)
+		case reflect.Map:
+			if f.value.Type().Elem().Kind() != reflect.String {
+				f.value.Set(reflect.Zero(f.value.Type()))
+				continue
+			}
+
+			for _, key := range f.value.MapKeys() {
+				f.value.SetMapIndex(key, reflect.ValueOf(redacted).Convert(f.value.Type().Elem()))
+			}
+		default:
+			f.value.Set(reflect.Zero(f.value.Type()))
+		}
+	}
+
+	for _, urls := range [][]string{out.Broker.URLs, out.NATS.URLs, out.Kafka.Brokers} {
+		for i, u := range urls {
+			urls[i] = redactURL(u)
+		}
+	}
+
+	return out
+}
+
+func (f field) display() string {
+	if f.secret {
+		if f.value.IsZero() {
+			return `""`
+		}
+
+		return redacted
+	}
+
+	return fmt.Sprintf("%v", f.value.Interface())
+}
+
+// equal treats nil and empty lists or maps as the same setting.
+func equal(a, b reflect.Value) bool {
+	if (a.Kind() == reflect.Slice || a.Kind() == reflect.Map) && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(out, v)
+
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+
+		for _, key := range v.MapKeys() {
+			out.SetMapIndex(key, deepCopy(v.MapIndex(key)))
+		}
+
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ClientConfig builds the TLS settings used when dialing the broker.
+func (t TLSConfig) ClientConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: t.ServerName,
+	}
+
+	if t.CAFile != "" {
+		pool, err := LoadCertPool(t.CAFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("CA bundle " + path + " contains no certificates")
+	}
+
+	return pool, nil
+}
+
+// Credentials returns the broker username and password, reading them from their
+// files when configured. Empty values let the url or the broker defaults apply.
+func (b BrokerConfig) Credentials() (string, string, error) {
+	username, password := b.Username, b.Password
+
+	if b.UsernameFile != "" {
+		data, err := os.ReadFile(b.UsernameFile)
+
+		if err != nil {
+			return "", "", fmt.Errorf("reading broker username: %w", err)
+		}
+
+		username = strings.TrimSpace(string(data))
+	}
+
+	if b.PasswordFile != "" {
+		data, err := os.ReadFile(b.PasswordFile)
+
+		if err != nil {
+			return "", "", fmt.Errorf("reading broker password: %w", err)
+		}
+
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+
+	return username, password, nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce absorbs the burst of events editors and ConfigMap updates produce.
+const reloadDebounce = 250 * time.Millisecond
+
+// Watch reloads the configuration whenever the file at path changes or the process
+// receives SIGHUP, until ctx is cancelled. args are the original command-line
+// arguments so flags and the environment keep their precedence over the file.
+// Invalid configurations are logged and leave the current one untouched.
+func Watch(ctx context.Context, store *Store, args []string, path string) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+
+	if path != "" {
+		watcher, err := fsnotify.NewWatcher()
+
+		if err != nil {
+			return err
+		}
+
+		defer watcher.Close()
+
+		// Watch the directory rather than the file so atomic renames and Kubernetes
+		// ConfigMap symlink swaps are picked up as well.
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("watching %s: %w", path, err)
+		}
+
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			slog.Info("SIGHUP received, reloading configuration")
+			reload(store, args)
+		case ev := <-events:
+			if affects(ev, path) {
+				debounce = time.After(reloadDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			slog.Info("configuration file changed, reloading", "path", path)
+			reload(store, args)
+		case err := <-watchErrors:
+			slog.Warn("configuration watch error", "error", err)
+		}
+	}
+}
+
+func affects(ev fsnotify.Event, path string) bool {
+	if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+		return false
+	}
+
+	name := filepath.Clean(ev.Name)
+
+	return name == filepath.Clean(path) || filepath.Base(name) == "..data"
+}
+
+func reload(store *Store, args []string) {
+	next, _, err := Load(args)
+
+	if err != nil {
+		slog.Error("configuration reload rejected, keeping the current one", "error", err)
+		return
+	}
+
+	changes := store.Reload(next)
+
+	if len(changes) == 0 {
+		slog.Info("configuration reload found no changes")
+		return
+	}
+
+	for _, c := range changes {
+		slog.Info("configuration changed", "path", c.Path, "old", c.Old, "new", c.New, "applied", c.Applied)
+	}
+}
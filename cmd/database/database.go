@@ -0,0 +1,34 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/glebarez/sqlite"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open connects to the configured database. sqlite is meant for development and
+// tests; it needs no cgo.
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+
+	switch cfg.Driver {
+	case "postgres":
+		dialector = postgres.Open(cfg.DSN)
+	case "sqlite":
+		dialector = sqlite.Open(cfg.DSN)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+
+	if err != nil {
+		return nil, fmt.Errorf("opening %s database: %w", cfg.Driver, err)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,283 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/rabbitmq/amqp091-go"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// FailureThreshold consecutive broker failures on a route take its service out of rotation.
+	FailureThreshold = 3
+
+	// FailureWindow is how long a failing route stays unhealthy without further failures.
+	FailureWindow = 30 * time.Second
+
+	// RecentFailures is how many failed publishes are kept for inspection.
+	RecentFailures = 50
+)
+
+// Services groups the routes whose health is reported together, so seat locking
+// can fail independently of the Strapi sync for example.
+var Services = map[string][]string{
+	"payments": {"payment_success", "payment_failure"},
+	"seats":    {"lock_seats", "unlock_seats"},
+	"mail":     {"send_mail"},
+	"strapi":   {"cast_creation", "cast_deletion", "movie_time_slot_creation", "movie_creation", "movie_deletion"},
+}
+
+// Monitor drives the grpc.health.v1 statuses from the broker connection, the
+// channel, flow control and the outcome of recent publishes. The overall status
+// ("") only follows the connection and channel, so liveness probes on it do not
+// restart every pod during a broker memory or disk alarm. The producer service
+// also follows flow control and suits readiness probes; each entry of Services
+// also follows its routes.
+type Monitor struct {
+	Config *config.Store
+
+	// Blocked reports broker flow control. Optional.
+	Blocked func() (string, bool)
+
+	server *health.Server
+
+	mu       sync.Mutex
+	down     error
+	failures map[string]*routeFailures
+	recent   []Failure
+}
+
+type routeFailures struct {
+	count int
+	last  time.Time
+}
+
+// Failure is a failed publish kept for operators to inspect.
+type Failure struct {
+	Time  time.Time `json:"time"`
+	Route string    `json:"route"`
+	Error string    `json:"error"`
+}
+
+// State is a point in time view of the broker and publish health.
+type State struct {
+	BrokerDown     string            `json:"broker_down,omitempty"`
+	Blocked        string            `json:"blocked,omitempty"`
+	Statuses       map[string]string `json:"statuses"`
+	RecentFailures []Failure         `json:"recent_failures"`
+}
+
+func NewMonitor(store *config.Store) *Monitor {
+	m := &Monitor{
+		Config:   store,
+		server:   health.NewServer(),
+		failures: map[string]*routeFailures{},
+	}
+
+	m.update()
+
+	return m
+}
+
+// Server is the grpc.health.v1 implementation to register on the gRPC server.
+func (m *Monitor) Server() healthpb.HealthServer {
+	return m.server
+}
+
+// WatchBroker marks everything NOT_SERVING once conn or ch is closed.
+func (m *Monitor) WatchBroker(conn *amqp091.Connection, ch *amqp091.Channel) {
+	connClosed := conn.NotifyClose(make(chan *amqp091.Error, 1))
+	chClosed := ch.NotifyClose(make(chan *amqp091.Error, 1))
+
+	go func() {
+		select {
+		case err := <-connClosed:
+			m.BrokerDown(closeReason("connection", err))
+		case err := <-chClosed:
+			m.BrokerDown(closeReason("channel", err))
+		}
+	}()
+}
+
+func closeReason(what string, err *amqp091.Error) error {
+	if err == nil {
+		return errors.New(what + " closed")
+	}
+
+	return errors.New(what + " closed: " + err.Error())
+}
+
+// BrokerDown reports that publishing is impossible until the process is restarted.
+func (m *Monitor) BrokerDown(reason error) {
+	slog.Error("broker unavailable, reporting NOT_SERVING", "error", reason)
+
+	m.mu.Lock()
+	m.down = reason
+	m.mu.Unlock()
+
+	m.update()
+}
+
+// Observe records the outcome of a publish on route. Every failure is kept in
+// the recent failures, but only broker side failures count against the route's
+// health; caller cancellations, disabled routes and flow control do not.
+func (m *Monitor) Observe(route string, err error) {
+	if err != nil {
+		m.mu.Lock()
+		m.recent = append(m.recent, Failure{Time: time.Now(), Route: route, Error: err.Error()})
+
+		if len(m.recent) > RecentFailures {
+			m.recent = m.recent[len(m.recent)-RecentFailures:]
+		}
+
+		m.mu.Unlock()
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, rpcerrors.ErrRouteDisabled), errors.Is(err, rpcerrors.ErrBackpressure):
+		return
+	}
+
+	m.mu.Lock()
+
+	f, ok := m.failures[route]
+
+	if !ok {
+		f = &routeFailures{}
+		m.failures[route] = f
+	}
+
+	if err == nil {
+		f.count = 0
+	} else {
+		if time.Since(f.last) >= FailureWindow {
+			f.count = 0
+		}
+
+		f.count++
+		f.last = time.Now()
+	}
+
+	m.mu.Unlock()
+
+	m.update()
+}
+
+// Run re-evaluates the statuses periodically, so failing routes recover after
+// FailureWindow and route changes from configuration reloads are reflected.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.update()
+		}
+	}
+}
+
+// Shutdown reports NOT_SERVING for everything so load balancers drain the
+// instance before the server stops.
+func (m *Monitor) Shutdown() {
+	m.server.Shutdown()
+}
+
+// Statuses returns the current serving status of every reported service.
+func (m *Monitor) Statuses() map[string]healthpb.HealthCheckResponse_ServingStatus {
+	return m.evaluate()
+}
+
+// State returns the broker state, service statuses and recent failures, newest first.
+func (m *Monitor) State() State {
+	state := State{Statuses: map[string]string{}}
+
+	for service, status := range m.evaluate() {
+		state.Statuses[service] = status.String()
+	}
+
+	if m.Blocked != nil {
+		state.Blocked, _ = m.Blocked()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.down != nil {
+		state.BrokerDown = m.down.Error()
+	}
+
+	state.RecentFailures = make([]Failure, 0, len(m.recent))
+
+	for i := len(m.recent) - 1; i >= 0; i-- {
+		state.RecentFailures = append(state.RecentFailures, m.recent[i])
+	}
+
+	return state
+}
+
+func (m *Monitor) update() {
+	for service, status := range m.evaluate() {
+		m.server.SetServingStatus(service, status)
+	}
+}
+
+func (m *Monitor) evaluate() map[string]healthpb.HealthCheckResponse_ServingStatus {
+	routes := m.Config.Load().Routes.All()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	connected := m.down == nil
+	brokerOK := connected
+
+	if m.Blocked != nil {
+		if _, blocked := m.Blocked(); blocked {
+			brokerOK = false
+		}
+	}
+
+	statuses := map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"": serving(connected),
+		rabbitmq_producer.RabbitmqProducerService_ServiceDesc.ServiceName: serving(brokerOK),
+	}
+
+	for service, names := range Services {
+		enabled := false
+		failing := false
+
+		for _, name := range names {
+			if route, ok := routes[name]; ok && route.Enabled {
+				enabled = true
+			}
+
+			if f, ok := m.failures[name]; ok && f.count >= FailureThreshold && time.Since(f.last) < FailureWindow {
+				failing = true
+			}
+		}
+
+		statuses[service] = serving(brokerOK && enabled && !failing)
+	}
+
+	return statuses
+}
+
+func serving(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
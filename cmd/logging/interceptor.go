@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const RequestIDHeader = "x-request-id"
+
+// UnaryServerInterceptor gives every RPC a request ID, taken from the caller's
+// x-request-id header when present and echoed back in the response headers, and
+// logs the outcome of the call.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		id := requestID(ctx)
+
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+		ctx = WithRequest(ctx, slog.String("request_id", id), slog.String("method", info.FullMethod))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		code := status.Code(err)
+
+		attrs := []any{
+			slog.String("code", code.String()),
+			slog.Duration("duration", time.Since(start)),
+		}
+
+		switch {
+		case code == codes.OK && strings.HasPrefix(info.FullMethod, "/grpc.health.v1."):
+			// Probes would drown everything else
+			slog.DebugContext(ctx, "rpc succeeded", attrs...)
+		case code == codes.OK:
+			slog.InfoContext(ctx, "rpc succeeded", attrs...)
+		case code == codes.Internal, code == codes.Unknown, code == codes.DataLoss:
+			slog.ErrorContext(ctx, "rpc failed", append(attrs, slog.String("error", status.Convert(err).Message()))...)
+		default:
+			slog.WarnContext(ctx, "rpc failed", append(attrs, slog.String("error", status.Convert(err).Message()))...)
+		}
+
+		return resp, err
+	}
+}
+
+// RequestID returns the ID of the request being handled, if any.
+func RequestID(ctx context.Context) string {
+	for _, a := range requestAttrs(ctx) {
+		if a.Key == "request_id" {
+			return a.Value.String()
+		}
+	}
+
+	return ""
+}
+
+func requestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if ids := md.Get(RequestIDHeader); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= 128 {
+		return ids[0]
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
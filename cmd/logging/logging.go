@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are always redacted, wherever
+// they appear in a record.
+var sensitiveKeys = map[string]bool{
+	"email":                true,
+	"to":                   true,
+	"name":                 true,
+	"customer_name":        true,
+	"phone":                true,
+	"phone_number":         true,
+	"street":               true,
+	"zipcode":              true,
+	"address":              true,
+	"card_last_four":       true,
+	"card_issuing_country": true,
+	"password":             true,
+	"token":                true,
+	"authorization":        true,
+	"x-api-key":            true,
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// New builds the service logger: JSON or text to w, request fields taken from
+// the context and PII redacted. level can be changed while the logger is in use.
+func New(w io.Writer, format string, level *slog.LevelVar) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var handler slog.Handler = slog.NewJSONHandler(w, opts)
+
+	if format == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// Setup installs the configured logger as the slog default and keeps its level
+// in sync with configuration reloads.
+func Setup(w io.Writer, store *config.Store) {
+	level := &slog.LevelVar{}
+	cfg := store.Load().Logging
+
+	level.Set(ParseLevel(cfg.Level))
+	slog.SetDefault(New(w, cfg.Format, level))
+
+	store.Subscribe(func(previous, next *config.Config) {
+		if previous.Logging.Level != next.Logging.Level {
+			level.Set(ParseLevel(next.Logging.Level))
+		}
+	})
+}
+
+// ParseLevel maps a configured level such as "debug" or "warn" to a slog level,
+// falling back to info. Configuration validation rejects unknown levels.
+func ParseLevel(s string) slog.Level {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+
+	return level
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	if a.Value.Kind() == slog.KindString {
+		if s := a.Value.String(); strings.Contains(s, "@") {
+			return slog.String(a.Key, emailPattern.ReplaceAllString(s, redacted))
+		}
+	}
+
+	return a
+}
+
+// RedactJSON masks the same keys and email addresses as the logger in a JSON
+// document. It reports whether anything was masked; bodies that are not JSON
+// are returned unchanged.
+func RedactJSON(body []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var doc any
+
+	if err := dec.Decode(&doc); err != nil {
+		return body, false
+	}
+
+	doc, changed := redactValue(doc)
+
+	if !changed {
+		return body, false
+	}
+
+	out, err := json.Marshal(doc)
+
+	if err != nil {
+		return body, false
+	}
+
+	return out, true
+}
+
+func redactValue(v any) (any, bool) {
+	changed := false
+
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if sensitiveKeys[strings.ToLower(key)] {
+				if value != nil && value != redacted {
+					v[key] = redacted
+					changed = true
+				}
+
+				continue
+			}
+
+			var c bool
+			v[key], c = redactValue(value)
+			changed = changed || c
+		}
+	case []any:
+		for i := range v {
+			var c bool
+			v[i], c = redactValue(v[i])
+			changed = changed || c
+		}
+	case string:
+		if strings.Contains(v, "@") {
+			masked := emailPattern.ReplaceAllString(v, redacted)
+			return masked, masked != v
+		}
+	}
+
+	return v, changed
+}
+
+// request carries the fields attached to every record logged while handling one RPC.
+type request struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type requestKey struct{}
+
+// WithRequest starts a new set of request fields on ctx.
+func WithRequest(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{attrs: attrs})
+}
+
+// Annotate adds fields to the current request, including records logged by
+// interceptors that run before the fields were known.
+func Annotate(ctx context.Context, attrs ...slog.Attr) {
+	req, ok := ctx.Value(requestKey{}).(*request)
+
+	if !ok {
+		return
+	}
+
+	req.mu.Lock()
+	defer req.mu.Unlock()
+
+	req.attrs = append(req.attrs, attrs...)
+}
+
+func requestAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+
+	req, ok := ctx.Value(requestKey{}).(*request)
+
+	if !ok {
+		return nil
+	}
+
+	req.mu.Lock()
+	defer req.mu.Unlock()
+
+	return append([]slog.Attr(nil), req.attrs...)
+}
+
+// contextHandler adds the request fields from the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := requestAttrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "producer"
+
+// Registry holds every metric of the service next to the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	RPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_requests_total",
+		Help:      "RPCs handled, by method and gRPC status code.",
+	}, []string{"method", "code"})
+
+	RPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_duration_seconds",
+		Help:      "Time to handle an RPC, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	PublishAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "publish_attempts_total",
+		Help:      "Messages handed to the broker.",
+	}, []string{"exchange", "routing_key"})
+
+	PublishFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "publish_failures_total",
+		Help:      "Publishes that failed before the broker acknowledged them.",
+	}, []string{"exchange", "routing_key"})
+
+	PublishConfirms = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "publish_confirms_total",
+		Help:      "Publisher confirms (acks) received from the broker.",
+	}, []string{"exchange", "routing_key"})
+
+	PublishNacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "publish_nacks_total",
+		Help:      "Publishes the broker negatively acknowledged.",
+	}, []string{"exchange", "routing_key"})
+
+	PublishReturns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "publish_returns_total",
+		Help:      "Mandatory publishes returned because no queue was bound for them.",
+	}, []string{"exchange", "routing_key"})
+
+	PublishDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "publish_duration_seconds",
+		Help:      "Time from publishing until the broker confirmed the message.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"exchange", "routing_key"})
+
+	PayloadSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "publish_payload_bytes",
+		Help:      "Size of published message bodies.",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+	}, []string{"exchange", "routing_key"})
+
+	// InFlight counts publishes waiting on the shared channel, i.e. how saturated it is.
+	InFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "channel_publishes_in_flight",
+		Help:      "Publishes currently waiting on the broker channel.",
+	})
+
+	SinkPublishes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_publishes_total",
+		Help:      "Publishes per backend when publishing to several, by outcome: confirmed, sent, nacked, failed, shadowed or dropped (too many mirror publishes in flight).",
+	}, []string{"sink", "route", "outcome"})
+
+	SinkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sink_publish_duration_seconds",
+		Help:      "Time from publishing to a backend until it acknowledged the message.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"sink"})
+
+	BookingTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "booking_transitions_total",
+		Help:      "Bookings entering each saga state.",
+	}, []string{"state"})
+
+	BookingStepFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "booking_step_failures_total",
+		Help:      "Saga steps that failed and will be retried, by the state they were in.",
+	}, []string{"state"})
+
+	ConnectRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broker_connect_retries_total",
+		Help:      "Failed attempts to connect to RabbitMQ at startup that were retried.",
+	})
+
+	// Reconnects only counts NATS: a lost RabbitMQ connection is not
+	// re-established, the service reports NOT_SERVING until it is restarted.
+	Reconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broker_reconnects_total",
+		Help:      "Broker connections re-established after they were lost.",
+	})
+
+	// There is no outbox backlog: events are published straight from the RPC.
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RPCRequests, RPCDuration,
+		PublishAttempts, PublishFailures, PublishConfirms, PublishNacks, PublishReturns,
+		PublishDuration, PayloadSize, InFlight, ConnectRetries, Reconnects, SinkPublishes, SinkDuration,
+		BookingTransitions, BookingStepFailures,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Serve exposes /metrics on addr until ctx is cancelled.
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("serving metrics", "address", addr)
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// UnaryServerInterceptor counts and times the producer service's RPCs.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		method := strings.TrimPrefix(info.FullMethod, "/"+rabbitmq_producer.RabbitmqProducerService_ServiceDesc.ServiceName+"/")
+
+		RPCRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+		RPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+		return resp, err
+	}
+}
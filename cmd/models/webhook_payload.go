@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
 
 type Payment struct {
 	Billing struct {
@@ -75,3 +78,23 @@ type Payment struct {
 	TotalAmount        int       `json:"total_amount"`
 	UpdatedAt          time.Time `json:"updated_at"`
 }
+
+// LogValue keeps customer, card and billing details out of the logs.
+func (p Payment) LogValue() slog.Value {
+	status := ""
+
+	if p.Status != nil {
+		status = *p.Status
+	}
+
+	return slog.GroupValue(
+		slog.String("payment_id", p.PaymentID),
+		slog.String("business_id", p.BusinessID),
+		slog.String("customer_id", p.Customer.CustomerID),
+		slog.String("status", status),
+		slog.Int("total_amount", p.TotalAmount),
+		slog.String("currency", p.Currency),
+		slog.String("payment_method", p.PaymentMethod),
+		slog.String("card_network", p.CardNetwork),
+	)
+}
@@ -0,0 +1,68 @@
+// Package ordering keeps the events of one entity in order across sharded
+// queues: every entity hashes to a fixed shard and its events carry increasing
+// sequence numbers.
+package ordering
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const (
+	// KeyHeader carries the entity the event is about.
+	KeyHeader = "x-entity-key"
+
+	// SequenceHeader carries the event's sequence number for its entity.
+	// Consumers can drop events older than the last one they applied.
+	SequenceHeader = "x-entity-sequence"
+
+	// ShardHeader carries the shard the event was routed to.
+	ShardHeader = "x-shard"
+)
+
+// Shard maps key onto one of shards buckets with jump consistent hashing, so
+// changing the number of shards only moves the keys that have to move.
+func Shard(key string, shards int) int {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	k := h.Sum64()
+
+	var b, j int64 = -1, 0
+
+	for j < int64(shards) {
+		b = j
+		k = k*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((k>>33)+1)))
+	}
+
+	return int(b)
+}
+
+// maxTracked bounds the entities a Sequencer remembers.
+const maxTracked = 100_000
+
+// Sequencer hands out increasing sequence numbers per entity. Numbers never
+// fall below the current time in microseconds, so they keep increasing across
+// restarts without being stored, as long as the clock does not go backwards.
+// The zero value is ready to use.
+type Sequencer struct {
+	mu   sync.Mutex
+	last map[string]int64
+}
+
+func (s *Sequencer) Next(key string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forgetting is safe, the clock keeps the next numbers higher
+	if s.last == nil || len(s.last) >= maxTracked {
+		s.last = map[string]int64{}
+	}
+
+	next := max(s.last[key]+1, time.Now().UnixMicro())
+	s.last[key] = next
+
+	return next
+}
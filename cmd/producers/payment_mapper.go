@@ -0,0 +1,120 @@
+package producers
+
+import (
+	"time"
+
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/models"
+)
+
+// paymentFromRequest converts the gRPC payment into the queue payload. It only uses
+// the generated getters so a sparse request can never panic the handler.
+func paymentFromRequest(in *rabbitmq_producer.Payment) models.Payment {
+	var requestPayload models.Payment
+
+	// Billing
+	requestPayload.Billing.City = in.GetBilling().GetCity()
+	requestPayload.Billing.Country = in.GetBilling().GetCountry()
+	requestPayload.Billing.State = in.GetBilling().GetState()
+	requestPayload.Billing.Street = in.GetBilling().GetStreet()
+	requestPayload.Billing.Zipcode = in.GetBilling().GetZipcode()
+
+	// General Info
+	cardIssuingCountry := in.GetCardIssuingCountry()
+	paymentStatus := in.GetStatus()
+
+	requestPayload.BrandID = in.GetBrandId()
+	requestPayload.BusinessID = in.GetBusinessId()
+	requestPayload.CardIssuingCountry = &cardIssuingCountry
+	requestPayload.CardLastFour = in.GetCardLastFour()
+	requestPayload.CardNetwork = in.GetCardNetwork()
+	requestPayload.CardType = in.GetCardType()
+	requestPayload.CreatedAt = in.GetCreatedAt().AsTime()
+	requestPayload.UpdatedAt = in.GetUpdatedAt().AsTime()
+	requestPayload.Currency = in.GetCurrency()
+	requestPayload.PaymentID = in.GetPaymentId()
+	requestPayload.PaymentLink = in.GetPaymentLink()
+	requestPayload.PaymentMethod = in.GetPaymentMethod()
+	requestPayload.PaymentMethodType = in.GetPaymentMethodType()
+	requestPayload.Status = &paymentStatus
+	requestPayload.SubscriptionID = in.GetSubscriptionId()
+	requestPayload.Tax = int(in.GetTax())
+	requestPayload.TotalAmount = int(in.GetTotalAmount())
+	requestPayload.SettlementAmount = int(in.GetSettlementAmount())
+	requestPayload.SettlementCurrency = in.GetSettlementCurrency()
+	requestPayload.SettlementTax = int(in.GetSettlementTax())
+	requestPayload.DigitalProductsDelivered = in.GetDigitalProductsDelivered()
+	requestPayload.DiscountID = in.GetDiscountId()
+	requestPayload.ErrorCode = in.GetErrorCode()
+	requestPayload.ErrorMessage = in.GetErrorMessage()
+
+	// Customer
+	requestPayload.Customer.CustomerID = in.GetCustomer().GetCustomerId()
+	requestPayload.Customer.Email = in.GetCustomer().GetEmail()
+	requestPayload.Customer.Name = in.GetCustomer().GetName()
+
+	// Product Cart
+	for _, p := range in.GetProductCart() {
+		requestPayload.ProductCart = append(requestPayload.ProductCart, struct {
+			ProductID string `json:"product_id"`
+			Quantity  int    `json:"quantity"`
+		}{
+			ProductID: p.GetProductId(),
+			Quantity:  int(p.GetQuantity()),
+		})
+	}
+
+	// Disputes
+	for _, d := range in.GetDisputes() {
+		requestPayload.Disputes = append(requestPayload.Disputes, struct {
+			Amount        string    `json:"amount"`
+			BusinessID    string    `json:"business_id"`
+			CreatedAt     time.Time `json:"created_at"`
+			Currency      string    `json:"currency"`
+			DisputeID     string    `json:"dispute_id"`
+			DisputeStage  string    `json:"dispute_stage"`
+			DisputeStatus string    `json:"dispute_status"`
+			PaymentID     string    `json:"payment_id"`
+			Remarks       string    `json:"remarks"`
+		}{
+			Amount:        d.GetAmount(),
+			BusinessID:    d.GetBusinessId(),
+			CreatedAt:     d.GetCreatedAt().AsTime(),
+			Currency:      d.GetCurrency(),
+			DisputeID:     d.GetDisputeId(),
+			DisputeStage:  d.GetDisputeStage(),
+			DisputeStatus: d.GetDisputeStatus(),
+			PaymentID:     d.GetPaymentId(),
+			Remarks:       d.GetRemarks(),
+		})
+	}
+
+	// Refunds
+	for _, rf := range in.GetRefunds() {
+		currency := rf.GetCurrency()
+
+		requestPayload.Refunds = append(requestPayload.Refunds, struct {
+			Amount     int       `json:"amount"`
+			BusinessID string    `json:"business_id"`
+			CreatedAt  time.Time `json:"created_at"`
+			Currency   *string   `json:"currency"`
+			IsPartial  bool      `json:"is_partial"`
+			PaymentID  string    `json:"payment_id"`
+			Reason     string    `json:"reason"`
+			RefundID   string    `json:"refund_id"`
+			Status     string    `json:"status"`
+		}{
+			Amount:     int(rf.GetAmount()),
+			BusinessID: rf.GetBusinessId(),
+			CreatedAt:  rf.GetCreatedAt().AsTime(),
+			Currency:   &currency,
+			IsPartial:  rf.GetIsPartial(),
+			PaymentID:  rf.GetPaymentId(),
+			Reason:     rf.GetReason(),
+			RefundID:   rf.GetRefundId(),
+			Status:     rf.GetStatus(),
+		})
+	}
+
+	return requestPayload
+}
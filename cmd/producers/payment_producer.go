@@ -4,781 +4,650 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"maps"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/audit"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
 	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/metrics"
 	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/models"
-	"github.com/rabbitmq/amqp091-go"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/ordering"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/tracing"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/versions"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers")
+
 type Producer struct {
-	Conn *amqp091.Channel
+	// Publisher is the broker the events go to.
+	Publisher broker.Publisher
+
+	// Config is read on every publish so reloaded routes apply immediately.
+	Config *config.Store
+
+	// Flow rejects publishes while the broker blocks the connection. Optional.
+	Flow *broker.FlowControl
+
+	// Health is told the outcome of every publish. Optional.
+	Health RouteObserver
+
+	// Audit records every publish. Optional.
+	Audit *audit.Log
+
+	// Versions stamps Strapi and payment events with their entity's version. Optional.
+	Versions *versions.Store
+
+	// Sequencer numbers the events of sharded routes. Optional, a process wide
+	// one is used otherwise.
+	Sequencer *ordering.Sequencer
 }
 
-func NewProducer(conn *amqp091.Channel) *Producer {
+var sequencer ordering.Sequencer
+
+// RouteObserver follows publish outcomes per route name.
+type RouteObserver interface {
+	Observe(route string, err error)
+}
+
+func NewProducer(publisher broker.Publisher, cfg *config.Store) *Producer {
 	return &Producer{
-		Conn: conn,
+		Publisher: publisher,
+		Config:    cfg,
 	}
 }
 
-func (p *Producer) Payment_Service_Producer(payload models.Payment) error {
+// MethodRoutes lists the routes each RPC publishes to, keyed by short method name.
+var MethodRoutes = map[string][]string{
+	"Payment_Service_Webhook_Producer": {"payment_success"},
+	"Payment_Service_Failure_Producer": {"payment_failure"},
+	"Lock_Seats":                       {"lock_seats"},
+	"Unlock_Seats":                     {"unlock_seats"},
+	"Send_Mail_Producer":               {"send_mail"},
+	"Cast_Service_Producer":            {"cast_creation"},
+	"Delete_Cast_Producer":             {"cast_deletion"},
+	"Movie_Time_Slot_Producer":         {"movie_time_slot_creation"},
+	"Movie_Producer":                   {"movie_creation"},
+	"Delete_Movie_Producer":            {"movie_deletion"},
+}
 
-	// Declare exchange
+func (p *Producer) routes() *config.Routes {
+	return &p.Config.Load().Routes
+}
 
-	err := p.Conn.ExchangeDeclare(
-		"payment_success_exchange",
-		"direct", // exchange type
-		true,     // durable
-		false,    // auto-deleted
-		false,    // internal
-		false,    // no-wait
-		nil,      // arguments
-	)
+// strapiEvent is the envelope the Strapi consumers expect on strapi_create.
+// Strapi events are keyed by the entry's UID, the only ID its create, updates
+// and delete all carry.
+type strapiEvent struct {
+	Action string `json:"action"`
+	Model  string `json:"model"`
+	Data   any    `json:"data"`
+}
 
-	if err != nil {
-		return err
+// publish sends msg on the named route and reports the outcome to the health
+// monitor and the audit log. Messages without an ID are given one so each
+// publish can be traced.
+func (p *Producer) publish(ctx context.Context, name string, msg broker.Message) error {
+	if msg.ID == "" {
+		msg.ID = uuid.NewString()
 	}
 
-	q, err := p.Conn.QueueDeclare(
-		"payment_service_success",
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
+	cfg := p.Config.Load()
+	route := *cfg.Routes.All()[name]
+	start := time.Now()
 
-	if err != nil {
-		return err
+	msg.Route = name
+
+	if route.Shards > 0 {
+		route, msg = p.order(route, msg)
 	}
 
-	// Bind the queue to the exchange
+	err := p.version(ctx, &msg)
 
-	err = p.Conn.QueueBind(
-		q.Name,                     // queue name
-		"payment_success_key",      // routing key
-		"payment_success_exchange", // exchange name
-		false,                      // no-wait
-		nil,                        // arguments
-	)
+	ctx, sinks := broker.WithSinkOutcomes(ctx)
 
-	if err != nil {
-		return err
+	if err == nil {
+		err = p.publishRoute(ctx, route, msg)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	body, err := json.Marshal(payload)
-
-	if err != nil {
-		return err
+	if p.Health != nil {
+		p.Health.Observe(name, err)
 	}
 
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"payment_success_exchange",
-		q.Name, // routing key
-		false,  // mandatory
-		false,  // immediate
-		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        []byte(body),
-			Timestamp:   time.Now(),
-		},
-	)
+	if p.Audit != nil {
+		record := audit.Record{
+			PublishedAt:   start,
+			CompletedAt:   time.Now(),
+			MessageID:     msg.ID,
+			CorrelationID: msg.CorrelationID,
+			Key:           msg.Key,
+			Version:       version(msg),
+			Sequence:      sequence(msg),
+			Replay:        msg.Headers[ReplayHeader] == true,
+			Route:         name,
+			Exchange:      route.Exchange,
+			RoutingKey:    route.RoutingKey,
+			Outcome:       audit.Outcome(err, confirmed(cfg, sinks.Map())),
+			Sinks:         sinks.Map(),
+		}
 
-	if err != nil {
-		return err
+		if err != nil {
+			record.Error = err.Error()
+		}
+
+		p.Audit.Record(ctx, record, msg.Body)
 	}
 
-	return nil
+	return err
 }
 
-func (p *Producer) Payment_Service_Failure_Producer(payload models.Payment) error {
+// versionedEntities names the kind of entity each versioned route is about.
+// Payment successes and failures share the payment's versions.
+var versionedEntities = map[string]string{
+	"payment_success":          "payment",
+	"payment_failure":          "payment",
+	"cast_creation":            "cast",
+	"cast_deletion":            "cast",
+	"movie_time_slot_creation": "movie-time-slot",
+	"movie_creation":           "movie",
+	"movie_deletion":           "movie",
+}
 
-	// Declare exchange
+// version stamps msg with the next version of its entity. Messages that
+// already carry a version, such as replays, keep it.
+func (p *Producer) version(ctx context.Context, msg *broker.Message) error {
+	kind, ok := versionedEntities[msg.Route]
+	id := entity(*msg)
 
-	err := p.Conn.ExchangeDeclare(
-		"payment_failure_exchange",
-		"direct", // exchange type
-		true,     // durable
-		false,    // auto-deleted
-		false,    // internal
-		false,    // no-wait
-		nil,      // arguments
-	)
+	if p.Versions == nil || !ok || id == "" {
+		return nil
+	}
 
-	if err != nil {
-		return err
+	if _, stamped := msg.Headers[versions.Header]; stamped {
+		return nil
 	}
 
-	q, err := p.Conn.QueueDeclare(
-		"payment_service_failure",
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
+	v, err := p.Versions.Next(ctx, kind+":"+id)
 
 	if err != nil {
 		return err
 	}
 
-	// Bind the queue to the exchange
+	headers := maps.Clone(msg.Headers)
 
-	err = p.Conn.QueueBind(
-		q.Name,                     // queue name
-		"payment_failure_key",      // routing key
-		"payment_failure_exchange", // exchange name
-		false,                      // no-wait
-		nil,                        // arguments
-	)
-
-	if err != nil {
-		return err
+	if headers == nil {
+		headers = map[string]any{}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	headers[versions.Header] = v
+	msg.Headers = headers
 
-	body, err := json.Marshal(payload)
+	return nil
+}
 
-	if err != nil {
-		return err
-	}
+// version returns the entity version msg was stamped with, 0 if none.
+func version(msg broker.Message) int64 {
+	v, _ := msg.Headers[versions.Header].(int64)
+	return v
+}
 
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"payment_failure_exchange",
-		q.Name, // routing key
-		false,  // mandatory
-		false,  // immediate
-		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        []byte(body),
-			Timestamp:   time.Now(),
-		},
-	)
+// sequence returns the sequence number msg was given, 0 if none.
+func sequence(msg broker.Message) int64 {
+	v, _ := msg.Headers[ordering.SequenceHeader].(int64)
+	return v
+}
 
-	if err != nil {
-		return err
+// entity returns the key a message is ordered by: the Strapi UID for Strapi
+// events, which the create and delete of one movie or cast share, otherwise
+// the message key.
+func entity(msg broker.Message) string {
+	if msg.CorrelationID != "" {
+		return msg.CorrelationID
 	}
 
-	return nil
+	return msg.Key
 }
 
-// Create producer for lock seats and unlock seats
+// order picks the shard of a sharded route for msg and numbers it within its
+// entity. Messages without an entity are spread by their ID and not numbered,
+// messages that already have a number, such as replays, keep it.
+func (p *Producer) order(route config.Route, msg broker.Message) (config.Route, broker.Message) {
+	headers := maps.Clone(msg.Headers)
 
-func (p *Producer) Lock_Seats(seatsIds []int) error {
+	if headers == nil {
+		headers = map[string]any{}
+	}
 
-	err := p.Conn.ExchangeDeclare(
-		"lock_seats",
-		"direct",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+	key := entity(msg)
+	shard := ordering.Shard(msg.ID, route.Shards)
 
-	if err != nil {
-		return err
-	}
+	if key != "" {
+		seq := p.Sequencer
 
-	q, err := p.Conn.QueueDeclare(
-		"lock_seats_queue",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+		if seq == nil {
+			seq = &sequencer
+		}
 
-	if err != nil {
-		return err
+		shard = ordering.Shard(key, route.Shards)
+		headers[ordering.KeyHeader] = key
+
+		if _, numbered := headers[ordering.SequenceHeader]; !numbered {
+			headers[ordering.SequenceHeader] = seq.Next(key)
+		}
 	}
 
-	err = p.Conn.QueueBind(
-		q.Name,
-		"lock_seats_key",
-		"lock_seats",
-		false,
-		nil,
-	)
+	headers[ordering.ShardHeader] = shard
+	msg.Headers = headers
 
-	if err != nil {
-		return err
-	}
+	return route.Shard(shard), msg
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+// confirmed reports whether a successful publish was acknowledged by the
+// broker. NATS and Kafka always acknowledge, event files never do. When
+// publishing to several backends it is the primary's outcome, and in all
+// mode every backend's.
+func confirmed(cfg *config.Config, sinks map[string]string) bool {
+	if len(sinks) > 0 {
+		backends := []string{cfg.Publisher.Backend}
 
-	bodyBytes, err := json.Marshal(seatsIds)
+		if cfg.Publisher.Mode == "all" {
+			backends = append(backends, cfg.Publisher.Secondaries...)
+		}
 
-	if err != nil {
-		return err
+		for _, backend := range backends {
+			if sinks[backend] != audit.OutcomeConfirmed {
+				return false
+			}
+		}
+
+		return true
 	}
 
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"lock_seats",
-		"lock_seats_key",
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        bodyBytes,
-			Timestamp:   time.Now(),
-		},
-	)
+	switch cfg.Publisher.Backend {
+	case "rabbitmq":
+		return cfg.Broker.PublisherConfirms
+	case "file":
+		return false
+	default:
+		return true
+	}
+}
 
-	if err != nil {
-		return err
+// messagingAttributes describes a publish on the configured backend. Event
+// files are no messaging system, so they only get the destination.
+func messagingAttributes(cfg *config.Config, route config.Route, msg broker.Message) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingOperationTypePublish,
+		semconv.MessagingDestinationName(route.Exchange),
+		semconv.MessagingMessageID(msg.ID),
+		semconv.MessagingMessageBodySize(len(msg.Body)),
 	}
 
-	fmt.Println("Published lock seats message in the queue")
+	switch cfg.Publisher.Backend {
+	case "rabbitmq":
+		attrs = append(attrs, semconv.MessagingSystemRabbitmq, semconv.MessagingRabbitmqDestinationRoutingKey(route.RoutingKey))
+	case "kafka":
+		attrs = append(attrs, semconv.MessagingSystemKafka, semconv.MessagingKafkaMessageKey(msg.Key))
+	case "nats":
+		attrs = append(attrs, semconv.MessagingSystemKey.String("nats"))
+	}
 
-	return nil
+	return attrs
 }
 
-// Unlock seats producer
-
-func (p *Producer) Unlock_Seats(seatsIds []int) error {
+// ReplayHeader marks re-published events; consumers can use it to tell them apart.
+const ReplayHeader = "x-replay"
 
-	err := p.Conn.ExchangeDeclare(
-		"unlock_seats",
-		"direct",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+// Replay re-publishes an archived event on its original route with its original
+// message and correlation IDs, so consumers that deduplicate by ID keep doing so.
+func (p *Producer) Replay(ctx context.Context, r audit.Record) error {
+	if _, ok := p.routes().All()[r.Route]; !ok {
+		return fmt.Errorf("unknown route %q", r.Route)
+	}
 
-	if err != nil {
-		return err
+	headers := map[string]any{
+		ReplayHeader:              true,
+		"x-original-published-at": r.PublishedAt.UTC().Format(time.RFC3339Nano),
 	}
 
-	q, err := p.Conn.QueueDeclare(
-		"unlock_seats_queue",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	// A replay carries old state, a new version or sequence would let it win over newer events
+	if r.Version > 0 {
+		headers[versions.Header] = r.Version
+	}
 
-	if err != nil {
-		return err
+	if r.Sequence > 0 {
+		headers[ordering.SequenceHeader] = r.Sequence
 	}
 
-	err = p.Conn.QueueBind(
-		q.Name,
-		"unlock_seats_key",
-		"unlock_seats",
-		false,
-		nil,
-	)
+	return p.publish(ctx, r.Route, broker.Message{
+		Body:          r.Payload,
+		ID:            r.MessageID,
+		CorrelationID: r.CorrelationID,
+		Key:           r.Key,
+		Headers:       headers,
+	})
+}
 
-	if err != nil {
-		return err
+// publishRoute declares the route topology and sends msg unless the caller has already
+// given up on the request, so abandoned RPCs never leave a message behind.
+func (p *Producer) publishRoute(ctx context.Context, route config.Route, msg broker.Message) error {
+	if !route.Enabled {
+		return fmt.Errorf("%w: %s", rpcerrors.ErrRouteDisabled, route.Exchange)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	if reason, blocked := p.Flow.Blocked(); blocked {
+		return fmt.Errorf("%w: broker blocked publishing: %s", rpcerrors.ErrBackpressure, reason)
+	}
 
-	bodyBytes, err := json.Marshal(seatsIds)
+	if err := p.Publisher.Declare(ctx, route); err != nil {
+		return err
+	}
 
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"unlock_seats",
-		"unlock_seats_key",
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        bodyBytes,
-			Timestamp:   time.Now(),
-		},
+	msg.ContentType = "application/json"
+	msg.Timestamp = time.Now()
+
+	ctx, span := tracer.Start(ctx, "publish "+route.Exchange,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(p.Config.Load(), route, msg)...),
 	)
+	defer span.End()
+
+	msg.Headers = tracing.Inject(ctx, msg.Headers)
+
+	err := p.send(ctx, route, msg)
 
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
 		return err
 	}
 
-	fmt.Println("Published unlock seats message in the queue")
+	slog.InfoContext(ctx, "message published",
+		"exchange", route.Exchange,
+		"routing_key", route.RoutingKey,
+		"message_id", msg.ID,
+		"bytes", len(msg.Body),
+	)
 
 	return nil
 }
 
-// Send email generation
+// send publishes msg and waits for the broker's confirm when it gives one.
+func (p *Producer) send(ctx context.Context, route config.Route, msg broker.Message) error {
+	labels := []string{route.Exchange, route.RoutingKey}
+
+	metrics.PublishAttempts.WithLabelValues(labels...).Inc()
+	metrics.PayloadSize.WithLabelValues(labels...).Observe(float64(len(msg.Body)))
+	metrics.InFlight.Inc()
+	defer metrics.InFlight.Dec()
+
+	start := time.Now()
+
+	confirmation, err := p.Publisher.Publish(ctx, route, msg)
 
-func (p *Producer) Send_Mail_Producer(contactInfo *rabbitmq_producer.Send_Mail_Producer_Request) error {
-
-	// 1. Ensure exchange exists (idempotent)
-	err := p.Conn.ExchangeDeclare(
-		"send_mail",
-		"direct",
-		true, // durable
-		false,
-		false,
-		false,
-		nil,
-	)
 	if err != nil {
-		return err
+		metrics.PublishFailures.WithLabelValues(labels...).Inc()
+		return fmt.Errorf("publish failed: %w", err)
 	}
 
-	// 2. Marshal payload
-	bodyBytes, err := json.Marshal(contactInfo)
-	if err != nil {
-		return err
+	if confirmation == nil {
+		metrics.PublishDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return nil
 	}
 
-	// Optional: generate a unique ID for tracking
-	// 3. Publish
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"send_mail",     // exchange
-		"send_mail_key", // routing key
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        bodyBytes,
-			Timestamp:   time.Now(),
-		},
-	)
+	acked, err := confirmation.Wait(ctx)
 
 	if err != nil {
-		return err
+		metrics.PublishFailures.WithLabelValues(labels...).Inc()
+		return fmt.Errorf("waiting for publisher confirm: %w", err)
+	}
+
+	metrics.PublishDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+	if !acked {
+		metrics.PublishNacks.WithLabelValues(labels...).Inc()
+		return fmt.Errorf("%w: %s/%s", rpcerrors.ErrNacked, route.Exchange, route.RoutingKey)
 	}
 
-	fmt.Println("Published send_mail message")
+	metrics.PublishConfirms.WithLabelValues(labels...).Inc()
 
 	return nil
 }
 
-func (p *Producer) Add_Cast_Producer(cast ExtendedCastAndCrew) error {
+func (p *Producer) Payment_Service_Producer(ctx context.Context, payload models.Payment) error {
 
-	// Declare queue
-	q, err := p.Conn.QueueDeclare(
-		"strapi_create",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return fmt.Errorf("queue declare failed: %w", err)
-	}
-
-	// Declare exchange
-	err = p.Conn.ExchangeDeclare(
-		"strapi_create_exchange",
-		"direct",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return fmt.Errorf("exchange declare failed: %w", err)
-	}
+	body, err := json.Marshal(payload)
 
-	// Bind queue → exchange
-	err = p.Conn.QueueBind(
-		q.Name,
-		"cast_creation", // routing key
-		"strapi_create_exchange",
-		false,
-		nil,
-	)
 	if err != nil {
-		return fmt.Errorf("queue bind failed: %w", err)
+		return err
 	}
 
-	// Prepare message
-	castEvent := struct {
-		Action string `json:"action"`
-		Model  string `json:"model"`
-		Data   any    `json:"data"`
-	}{
-		Action: "create",
-		Model:  "cast-and-crew",
-		Data:   cast,
-	}
+	return p.publish(ctx, "payment_success", broker.Message{
+		Key:  payload.PaymentID,
+		Body: body,
+	})
+}
 
-	payload, err := json.Marshal(castEvent)
-	if err != nil {
-		return err
-	}
+func (p *Producer) Payment_Service_Failure_Producer(ctx context.Context, payload models.Payment) error {
+
+	body, err := json.Marshal(payload)
 
-	// Publish with unique ID
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"strapi_create_exchange",
-		"cast_creation",
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType:   "application/json",
-			Body:          payload,
-			Timestamp:     time.Now(),
-			MessageId:     cast.StarpiCastUid,
-			CorrelationId: cast.StarpiCastUid,
-		},
-	)
 	if err != nil {
-		return fmt.Errorf("publish failed: %w", err)
+		return err
 	}
 
-	fmt.Println("Published cast creation message in the queue")
-	return nil
+	return p.publish(ctx, "payment_failure", broker.Message{
+		Key:  payload.PaymentID,
+		Body: body,
+	})
 }
 
-func (p *Producer) Delete_Cast_Producer(cast ExtendedCastAndCrew) error {
-	q, err := p.Conn.QueueDeclare(
-		"strapi_create",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+// Create producer for lock seats and unlock seats
 
-	if err != nil {
-		return fmt.Errorf("queue declare failed: %w", err)
-	}
+func (p *Producer) Lock_Seats(ctx context.Context, seatsIds []int) error {
 
-	err = p.Conn.ExchangeDeclare(
-		"strapi_create_exchange",
-		"direct",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	bodyBytes, err := json.Marshal(seatsIds)
 
 	if err != nil {
-		return fmt.Errorf("exchange declare failed: %w", err)
+		return err
 	}
 
-	err = p.Conn.QueueBind(
-		q.Name,
-		"cast_deletion",
-		"strapi_create_exchange",
-		false,
-		nil,
-	)
+	err = p.publish(ctx, "lock_seats", broker.Message{
+		Body: bodyBytes,
+	})
 
 	if err != nil {
-		return fmt.Errorf("queue bind failed: %w", err)
+		return err
 	}
 
-	payload := struct {
-		Action string `json:"action"`
-		Model  string `json:"model"`
-		Data   any    `json:"data"`
-	}{
-		Action: "delete",
-		Model:  "cast-and-crew",
-		Data:   cast,
-	}
+	return nil
+}
 
-	body, err := json.Marshal(payload)
+// Unlock seats producer
+
+func (p *Producer) Unlock_Seats(ctx context.Context, seatsIds []int) error {
+
+	bodyBytes, err := json.Marshal(seatsIds)
 
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"strapi_create_exchange",
-		"cast_deletion",
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType:   "application/json",
-			Body:          body,
-			Timestamp:     time.Now(),
-			MessageId:     cast.StarpiCastUid,
-			CorrelationId: cast.StarpiCastUid,
-		},
-	)
+	err = p.publish(ctx, "unlock_seats", broker.Message{
+		Body: bodyBytes,
+	})
 
 	if err != nil {
-		return fmt.Errorf("publish failed: %w", err)
+		return err
 	}
 
-	fmt.Println("Published cast deletion message in the queue")
 	return nil
 }
 
-func (p *Producer) Movie_Time_Slot_Producer(payload MovieTimeSlotPayload) error {
+// Send email generation
 
-	q, err := p.Conn.QueueDeclare(
-		"strapi_create",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+func (p *Producer) Send_Mail_Producer(ctx context.Context, contactInfo *rabbitmq_producer.Send_Mail_Producer_Request) error {
+
+	bodyBytes, err := json.Marshal(contactInfo)
 
 	if err != nil {
-		return fmt.Errorf("queue declare failed: %w", err)
+		return err
 	}
 
-	err = p.Conn.ExchangeDeclare(
-		"strapi_create_exchange",
-		"direct",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	err = p.publish(ctx, "send_mail", broker.Message{
+		Body: bodyBytes,
+	})
 
 	if err != nil {
-		return fmt.Errorf("exchange declare failed: %w", err)
+		return err
 	}
 
-	err = p.Conn.QueueBind(
-		q.Name,
-		"movie_time_slot_creation",
-		"strapi_create_exchange",
-		false,
-		nil,
-	)
+	return nil
+}
 
-	if err != nil {
-		return fmt.Errorf("queue bind failed: %w", err)
-	}
+func (p *Producer) Add_Cast_Producer(ctx context.Context, cast ExtendedCastAndCrew) error {
 
-	payloadEvent := struct {
-		Action string `json:"action"`
-		Model  string `json:"model"`
-		Data   any    `json:"data"`
-	}{
+	payload, err := json.Marshal(strapiEvent{
 		Action: "create",
-		Model:  "movie-time-slot",
-		Data:   payload,
-	}
-
-	body, err := json.Marshal(payloadEvent)
+		Model:  "cast-and-crew",
+		Data:   cast,
+	})
 
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"strapi_create_exchange",
-		"movie_time_slot_creation",
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType:   "application/json",
-			Body:          body,
-			Timestamp:     time.Now(),
-			MessageId:     payload.StarpiMovieUid,
-			CorrelationId: payload.StarpiMovieUid,
-		},
-	)
+	err = p.publish(ctx, "cast_creation", broker.Message{
+		Key:           cast.StarpiCastUid,
+		Body:          payload,
+		ID:            cast.StarpiCastUid,
+		CorrelationID: cast.StarpiCastUid,
+	})
 
 	if err != nil {
-		return fmt.Errorf("publish failed: %w", err)
+		return err
 	}
 
-	fmt.Println("Published movie time slot creation message in the queue")
 	return nil
 }
 
-func (p *Producer) Movie_Producer(payload MoviePayload) error {
-	q, err := p.Conn.QueueDeclare(
-		"strapi_create",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+func (p *Producer) Delete_Cast_Producer(ctx context.Context, cast ExtendedCastAndCrew) error {
+
+	body, err := json.Marshal(strapiEvent{
+		Action: "delete",
+		Model:  "cast-and-crew",
+		Data:   cast,
+	})
 
 	if err != nil {
-		return fmt.Errorf("queue declare failed: %w", err)
+		return err
 	}
 
-	err = p.Conn.ExchangeDeclare(
-		"strapi_create_exchange",
-		"direct",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	err = p.publish(ctx, "cast_deletion", broker.Message{
+		Key:           cast.StarpiCastUid,
+		Body:          body,
+		ID:            cast.StarpiCastUid,
+		CorrelationID: cast.StarpiCastUid,
+	})
 
 	if err != nil {
-		return fmt.Errorf("exchange declare failed: %w", err)
+		return err
 	}
 
-	err = p.Conn.QueueBind(
-		q.Name,
-		"movie_creation",
-		"strapi_create_exchange",
-		false,
-		nil,
-	)
+	return nil
+}
 
-	if err != nil {
-		return fmt.Errorf("queue bind failed: %w", err)
-	}
+func (p *Producer) Movie_Time_Slot_Producer(ctx context.Context, payload MovieTimeSlotPayload) error {
 
-	payloadEvent := struct {
-		Action string `json:"action"`
-		Model  string `json:"model"`
-		Data   any    `json:"data"`
-	}{
+	body, err := json.Marshal(strapiEvent{
 		Action: "create",
-		Model:  "movie",
+		Model:  "movie-time-slot",
 		Data:   payload,
-	}
-
-	body, err := json.Marshal(payloadEvent)
+	})
 
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"strapi_create_exchange",
-		"movie_creation",
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType:   "application/json",
-			Body:          body,
-			Timestamp:     time.Now(),
-			MessageId:     payload.StarpiMovieUid,
-			CorrelationId: payload.StarpiMovieUid,
-		},
-	)
+	err = p.publish(ctx, "movie_time_slot_creation", broker.Message{
+		Key:           payload.StarpiMovieUid,
+		Body:          body,
+		ID:            payload.StarpiMovieUid,
+		CorrelationID: payload.StarpiMovieUid,
+	})
 
 	if err != nil {
-		return fmt.Errorf("publish failed: %w", err)
+		return err
 	}
 
-	fmt.Println("Published movie creation message in the queue")
 	return nil
 }
 
-func (p *Producer) Delete_Movie_Producer(payload MoviePayload) error {
-	q, err := p.Conn.QueueDeclare(
-		"strapi_create",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+func (p *Producer) Movie_Producer(ctx context.Context, payload MoviePayload) error {
+
+	body, err := json.Marshal(strapiEvent{
+		Action: "create",
+		Model:  "movie",
+		Data:   payload,
+	})
 
 	if err != nil {
-		return fmt.Errorf("queue declare failed: %w", err)
+		return err
 	}
 
-	err = p.Conn.ExchangeDeclare(
-		"strapi_create_exchange",
-		"direct",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	err = p.publish(ctx, "movie_creation", broker.Message{
+		Key:           payload.StarpiMovieUid,
+		Body:          body,
+		ID:            payload.StarpiMovieUid,
+		CorrelationID: payload.StarpiMovieUid,
+	})
 
 	if err != nil {
-		return fmt.Errorf("exchange declare failed: %w", err)
+		return err
 	}
 
-	err = p.Conn.QueueBind(
-		q.Name,
-		"movie_deletion",
-		"strapi_create_exchange",
-		false,
-		nil,
-	)
+	return nil
+}
 
-	if err != nil {
-		return fmt.Errorf("queue bind failed: %w", err)
-	}
+func (p *Producer) Delete_Movie_Producer(ctx context.Context, payload MoviePayload) error {
 
-	fmt.Printf("payload received in delete movie producer : %+v", payload)
+	slog.DebugContext(ctx, "deleting movie", "movie_id", payload.ID, "strapi_movie_uid", payload.StarpiMovieUid)
 
-	payloadEvent := struct {
-		Action string `json:"action"`
-		Model  string `json:"model"`
-		Data   any    `json:"data"`
-	}{
+	body, err := json.Marshal(strapiEvent{
 		Action: "delete",
 		Model:  "movie",
 		Data:   payload,
-	}
-
-	body, err := json.Marshal(payloadEvent)
+	})
 
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	err = p.Conn.PublishWithContext(
-		ctx,
-		"strapi_create_exchange",
-		"movie_deletion",
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType:   "application/json",
-			Body:          body,
-			Timestamp:     time.Now(),
-			MessageId:     payload.StarpiMovieUid,
-			CorrelationId: payload.StarpiMovieUid,
-		},
-	)
+	err = p.publish(ctx, "movie_deletion", broker.Message{
+		Key:           payload.StarpiMovieUid,
+		Body:          body,
+		ID:            payload.StarpiMovieUid,
+		CorrelationID: payload.StarpiMovieUid,
+	})
 
 	if err != nil {
-		return fmt.Errorf("publish failed: %w", err)
+		return err
 	}
 
-	fmt.Println("Published movie deletion message in the queue")
 	return nil
 }
 
@@ -786,6 +655,6 @@ type VenuePayload struct {
 	Venueid string `json:"venueid"`
 }
 
-func (p *Producer) Add_Venue(payload VenuePayload) error {
+func (p *Producer) Add_Venue(ctx context.Context, payload VenuePayload) error {
 	return nil
 }
@@ -2,12 +2,16 @@ package producers
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
 	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
 	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/models"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/saga"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/validation"
+	"google.golang.org/grpc"
 )
 
 type ExtendedCastAndCrew struct {
@@ -25,398 +29,148 @@ type MoviePayload struct {
 	StarpiMovieUid string `json:"strapi_movie_uid"`
 }
 
+// Rabbitmq_Producer_Service fills the Error field of every response it returns:
+// empty on success and the status message on failure. gRPC drops the response
+// of a failed call, so remote clients only get the status, with its code,
+// message and details such as RetryInfo. The field reaches in-process callers
+// and interceptors.
 type Rabbitmq_Producer_Service struct {
 	rabbitmq_producer.UnimplementedRabbitmqProducerServiceServer
 	Producer Producer
+
+	// Saga follows the bookings callers name with the x-booking-id header. Optional.
+	Saga *saga.Orchestrator
+
+	// Config supplies the per-method timeouts. A shorter caller deadline always wins.
+	Config *config.Store
 }
 
 func NewRabbitmq_Producer_Service() *Rabbitmq_Producer_Service {
 	return &Rabbitmq_Producer_Service{}
 }
 
-func (r *Rabbitmq_Producer_Service) Payment_Service_Webhook_Producer(ctx context.Context, in *rabbitmq_producer.Payment_Service_Producer_Request) (*rabbitmq_producer.Payment_Service_Producer_Response, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+// publish runs fn with the caller's context capped by the method timeout and converts
+// its outcome into a gRPC status error. fn keeps the same context, so once the caller
+// cancels or the cap expires it stops before publishing and its goroutine exits.
+func (r *Rabbitmq_Producer_Service) publish(ctx context.Context, fn func(ctx context.Context) error) error {
+	method, _ := grpc.Method(ctx)
+	limit := r.Config.Load().MethodTimeout(method)
+
+	ctx, cancel := context.WithTimeout(ctx, limit)
 	defer cancel()
 
 	done := make(chan error, 1)
 
 	go func() {
-		var requestPayload models.Payment
-
-		// Billing
-		requestPayload.Billing.City = in.PaymentPayload.Billing.City
-		requestPayload.Billing.Country = in.PaymentPayload.Billing.Country
-		requestPayload.Billing.State = in.PaymentPayload.Billing.State
-		requestPayload.Billing.Street = in.PaymentPayload.Billing.Street
-		requestPayload.Billing.Zipcode = in.PaymentPayload.Billing.Zipcode
-
-		// General Info
-		requestPayload.BrandID = in.PaymentPayload.BrandId
-		requestPayload.BusinessID = in.PaymentPayload.BusinessId
-		requestPayload.CardIssuingCountry = &in.PaymentPayload.CardIssuingCountry
-		requestPayload.CardLastFour = in.PaymentPayload.CardLastFour
-		requestPayload.CardNetwork = in.PaymentPayload.CardNetwork
-		requestPayload.CardType = in.PaymentPayload.CardType
-		requestPayload.CreatedAt = in.PaymentPayload.CreatedAt.AsTime()
-		requestPayload.UpdatedAt = in.PaymentPayload.UpdatedAt.AsTime()
-		requestPayload.Currency = in.PaymentPayload.Currency
-		requestPayload.PaymentID = in.PaymentPayload.PaymentId
-		requestPayload.PaymentLink = in.PaymentPayload.PaymentLink
-		requestPayload.PaymentMethod = in.PaymentPayload.PaymentMethod
-		requestPayload.PaymentMethodType = in.PaymentPayload.PaymentMethodType
-		requestPayload.Status = &in.PaymentPayload.Status
-		requestPayload.SubscriptionID = in.PaymentPayload.SubscriptionId
-		requestPayload.Tax = int(in.PaymentPayload.Tax)
-		requestPayload.TotalAmount = int(in.PaymentPayload.TotalAmount)
-		requestPayload.SettlementAmount = int(in.PaymentPayload.SettlementAmount)
-		requestPayload.SettlementCurrency = in.PaymentPayload.SettlementCurrency
-		requestPayload.SettlementTax = int(in.PaymentPayload.SettlementTax)
-		requestPayload.DigitalProductsDelivered = in.PaymentPayload.DigitalProductsDelivered
-		requestPayload.DiscountID = in.PaymentPayload.DiscountId
-		requestPayload.ErrorCode = in.PaymentPayload.ErrorCode
-		requestPayload.ErrorMessage = in.PaymentPayload.ErrorMessage
-
-		// Customer
-		requestPayload.Customer.CustomerID = in.PaymentPayload.Customer.CustomerId
-		requestPayload.Customer.Email = in.PaymentPayload.Customer.Email
-		requestPayload.Customer.Name = in.PaymentPayload.Customer.Name
-
-		// Product Cart
-		for _, p := range in.PaymentPayload.ProductCart {
-			requestPayload.ProductCart = append(requestPayload.ProductCart, struct {
-				ProductID string `json:"product_id"`
-				Quantity  int    `json:"quantity"`
-			}{
-				ProductID: p.ProductId,
-				Quantity:  int(p.Quantity),
-			})
-		}
-
-		// Disputes
-		for _, d := range in.PaymentPayload.Disputes {
-			requestPayload.Disputes = append(requestPayload.Disputes, struct {
-				Amount        string    `json:"amount"`
-				BusinessID    string    `json:"business_id"`
-				CreatedAt     time.Time `json:"created_at"`
-				Currency      string    `json:"currency"`
-				DisputeID     string    `json:"dispute_id"`
-				DisputeStage  string    `json:"dispute_stage"`
-				DisputeStatus string    `json:"dispute_status"`
-				PaymentID     string    `json:"payment_id"`
-				Remarks       string    `json:"remarks"`
-			}{
-				Amount:        d.Amount,
-				BusinessID:    d.BusinessId,
-				CreatedAt:     d.CreatedAt.AsTime(),
-				Currency:      d.Currency,
-				DisputeID:     d.DisputeId,
-				DisputeStage:  d.DisputeStage,
-				DisputeStatus: d.DisputeStatus,
-				PaymentID:     d.PaymentId,
-				Remarks:       d.Remarks,
-			})
-		}
-
-		// Refunds
-		for _, rf := range in.PaymentPayload.Refunds {
-			requestPayload.Refunds = append(requestPayload.Refunds, struct {
-				Amount     int       `json:"amount"`
-				BusinessID string    `json:"business_id"`
-				CreatedAt  time.Time `json:"created_at"`
-				Currency   *string   `json:"currency"`
-				IsPartial  bool      `json:"is_partial"`
-				PaymentID  string    `json:"payment_id"`
-				Reason     string    `json:"reason"`
-				RefundID   string    `json:"refund_id"`
-				Status     string    `json:"status"`
-			}{
-				Amount:     int(rf.Amount),
-				BusinessID: rf.BusinessId,
-				CreatedAt:  rf.CreatedAt.AsTime(),
-				Currency:   &rf.Currency,
-				IsPartial:  rf.IsPartial,
-				PaymentID:  rf.PaymentId,
-				Reason:     rf.Reason,
-				RefundID:   rf.RefundId,
-				Status:     rf.Status,
-			})
-		}
-
-		// Metadata (if provided as JSON string map)
-		// if in.PaymentPayload.Metadata != nil {
-		// 	requestPayload.Metadata = make(map[string]interface{})
-		// 	for key, val := range in.PaymentPayload.Metadata.Fields {
-		// 		// Simplified conversion
-		// 		requestPayload.Metadata[key] = val.GetStringValue()
-		// 	}
-		// }
-
-		// Send to RabbitMQ
-		err := r.Producer.Payment_Service_Producer(requestPayload)
-
-		done <- err
+		done <- fn(ctx)
 	}()
 
 	select {
 	case <-ctx.Done():
-		fmt.Println("Timeout as request took more than 10 seconds")
-		return &rabbitmq_producer.Payment_Service_Producer_Response{
-			Error: ctx.Err().Error(),
-		}, ctx.Err()
+		slog.WarnContext(ctx, "rpc abandoned", "timeout", limit, "error", ctx.Err())
+		return rpcerrors.FromError(ctx.Err())
 	case err := <-done:
-		if err != nil {
-			return nil, err
-		}
+		return rpcerrors.FromError(err)
 	}
-
-	return &rabbitmq_producer.Payment_Service_Producer_Response{
-		Error: "",
-	}, nil
 }
 
-func (r *Rabbitmq_Producer_Service) Lock_Seats(ctx context.Context, in *rabbitmq_producer.Lock_Seats_Request) (*rabbitmq_producer.Lock_Seats_Response, error) {
+func (r *Rabbitmq_Producer_Service) Payment_Service_Webhook_Producer(ctx context.Context, in *rabbitmq_producer.Payment_Service_Producer_Request) (*rabbitmq_producer.Payment_Service_Producer_Response, error) {
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+	requestPayload := paymentFromRequest(in.GetPaymentPayload())
 
-	done := make(chan error, 1)
+	// The booking moves first: it ignores a repeated payment, while a failed
+	// step after the publish would have the retry send the event twice
+	err := r.publish(ctx, func(ctx context.Context) error {
+		if id := saga.BookingID(ctx, in.GetPaymentPayload()); r.Saga != nil && id != "" {
+			if err := r.Saga.PaymentSucceeded(ctx, id, requestPayload.PaymentID, customer(requestPayload)); err != nil {
+				return err
+			}
+		}
 
-	go func() {
-		var seatIds []int
+		return r.Producer.Payment_Service_Producer(ctx, requestPayload)
+	})
 
-		for _, v := range in.SeatIds {
-			seatIds = append(seatIds, int(v))
-		}
+	return &rabbitmq_producer.Payment_Service_Producer_Response{
+		Error: rpcerrors.Message(err),
+	}, err
+}
 
-		err := r.Producer.Lock_Seats(seatIds)
+// customer returns who the booking mails of a payment go to.
+func customer(p models.Payment) saga.Customer {
+	return saga.Customer{Email: p.Customer.Email, Name: p.Customer.Name}
+}
 
-		done <- err
-	}()
+func (r *Rabbitmq_Producer_Service) Lock_Seats(ctx context.Context, in *rabbitmq_producer.Lock_Seats_Request) (*rabbitmq_producer.Lock_Seats_Response, error) {
 
-	select {
-	case <-ctx.Done():
-		fmt.Println("Timeout 10 seconds reached")
-	case err := <-done:
+	var seatIds []int
 
-		if err != nil {
-			return nil, err
-		}
+	for _, v := range in.SeatIds {
+		seatIds = append(seatIds, int(v))
 	}
 
+	err := r.publish(ctx, func(ctx context.Context) error {
+		if id := saga.BookingID(ctx, nil); r.Saga != nil && id != "" {
+			return r.Saga.Start(ctx, id, seatIds, saga.CustomerFromContext(ctx))
+		}
+
+		return r.Producer.Lock_Seats(ctx, seatIds)
+	})
+
 	return &rabbitmq_producer.Lock_Seats_Response{
-		Error: "",
-	}, nil
+		Error: rpcerrors.Message(err),
+	}, err
 }
 
 func (r *Rabbitmq_Producer_Service) Unlock_Seats(ctx context.Context, in *rabbitmq_producer.Unlock_Seats_Request) (*rabbitmq_producer.Unlock_Seats_Response, error) {
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
+	var seatIds []int
 
-	go func() {
-		var seatIds []int
-
-		for _, v := range in.SeatIds {
-			seatIds = append(seatIds, int(v))
-		}
-
-		err := r.Producer.Unlock_Seats(seatIds)
-
-		done <- err
-	}()
-
-	select {
-	case <-ctx.Done():
-		fmt.Println("Timeout 10 second")
-		return nil, errors.New("timeout 10 second exceeded for sending Unlock seats into the queue")
-	case err := <-done:
-		if err != nil {
-			return nil, err
-		}
+	for _, v := range in.SeatIds {
+		seatIds = append(seatIds, int(v))
 	}
 
+	err := r.publish(ctx, func(ctx context.Context) error {
+		return r.Producer.Unlock_Seats(ctx, seatIds)
+	})
+
 	return &rabbitmq_producer.Unlock_Seats_Response{
-		Error: "",
-	}, nil
+		Error: rpcerrors.Message(err),
+	}, err
 }
 
 func (r *Rabbitmq_Producer_Service) Send_Mail_Producer(ctx context.Context, in *rabbitmq_producer.Send_Mail_Producer_Request) (*rabbitmq_producer.Send_Mail_Producer_Response, error) {
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
-
-	go func() {
-		err := r.Producer.Send_Mail_Producer(in)
-		done <- err
-	}()
-
-	select {
-	case err := <-done:
-		if err != nil {
-			return nil, err
-		}
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	}
+	err := r.publish(ctx, func(ctx context.Context) error {
+		return r.Producer.Send_Mail_Producer(ctx, in)
+	})
 
 	return &rabbitmq_producer.Send_Mail_Producer_Response{
-		Error: "",
-	}, nil
+		Error: rpcerrors.Message(err),
+	}, err
 }
 
 func (r *Rabbitmq_Producer_Service) Payment_Service_Failure_Producer(ctx context.Context, in *rabbitmq_producer.Payment_Service_Producer_Request) (*rabbitmq_producer.Payment_Service_Producer_Response, error) {
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
+	requestPayload := paymentFromRequest(in.GetPaymentPayload())
 
-	go func() {
-		var requestPayload models.Payment
-
-		// Billing
-		requestPayload.Billing.City = in.PaymentPayload.Billing.City
-		requestPayload.Billing.Country = in.PaymentPayload.Billing.Country
-		requestPayload.Billing.State = in.PaymentPayload.Billing.State
-		requestPayload.Billing.Street = in.PaymentPayload.Billing.Street
-		requestPayload.Billing.Zipcode = in.PaymentPayload.Billing.Zipcode
-
-		// General Info
-		requestPayload.BrandID = in.PaymentPayload.BrandId
-		requestPayload.BusinessID = in.PaymentPayload.BusinessId
-		requestPayload.CardIssuingCountry = &in.PaymentPayload.CardIssuingCountry
-		requestPayload.CardLastFour = in.PaymentPayload.CardLastFour
-		requestPayload.CardNetwork = in.PaymentPayload.CardNetwork
-		requestPayload.CardType = in.PaymentPayload.CardType
-		requestPayload.CreatedAt = in.PaymentPayload.CreatedAt.AsTime()
-		requestPayload.UpdatedAt = in.PaymentPayload.UpdatedAt.AsTime()
-
-		requestPayload.Currency = in.PaymentPayload.Currency
-		requestPayload.PaymentID = in.PaymentPayload.PaymentId
-		requestPayload.PaymentLink = in.PaymentPayload.PaymentLink
-		requestPayload.PaymentMethod = in.PaymentPayload.PaymentMethod
-		requestPayload.PaymentMethodType = in.PaymentPayload.PaymentMethodType
-		requestPayload.Status = &in.PaymentPayload.Status
-		requestPayload.SubscriptionID = in.PaymentPayload.SubscriptionId
-		requestPayload.Tax = int(in.PaymentPayload.Tax)
-		requestPayload.TotalAmount = int(in.PaymentPayload.TotalAmount)
-		requestPayload.SettlementAmount = int(in.PaymentPayload.SettlementAmount)
-		requestPayload.SettlementCurrency = in.PaymentPayload.SettlementCurrency
-		requestPayload.SettlementTax = int(in.PaymentPayload.SettlementTax)
-		requestPayload.DigitalProductsDelivered = in.PaymentPayload.DigitalProductsDelivered
-		requestPayload.DiscountID = in.PaymentPayload.DiscountId
-		requestPayload.ErrorCode = in.PaymentPayload.ErrorCode
-		requestPayload.ErrorMessage = in.PaymentPayload.ErrorMessage
-
-		// Customer
-		requestPayload.Customer.CustomerID = in.PaymentPayload.Customer.CustomerId
-		requestPayload.Customer.Email = in.PaymentPayload.Customer.Email
-		requestPayload.Customer.Name = in.PaymentPayload.Customer.Name
-
-		// Product Cart
-		for _, p := range in.PaymentPayload.ProductCart {
-			requestPayload.ProductCart = append(requestPayload.ProductCart, struct {
-				ProductID string `json:"product_id"`
-				Quantity  int    `json:"quantity"`
-			}{
-				ProductID: p.ProductId,
-				Quantity:  int(p.Quantity),
-			})
-		}
-
-		// Disputes
-		for _, d := range in.PaymentPayload.Disputes {
-			requestPayload.Disputes = append(requestPayload.Disputes, struct {
-				Amount        string    `json:"amount"`
-				BusinessID    string    `json:"business_id"`
-				CreatedAt     time.Time `json:"created_at"`
-				Currency      string    `json:"currency"`
-				DisputeID     string    `json:"dispute_id"`
-				DisputeStage  string    `json:"dispute_stage"`
-				DisputeStatus string    `json:"dispute_status"`
-				PaymentID     string    `json:"payment_id"`
-				Remarks       string    `json:"remarks"`
-			}{
-				Amount:        d.Amount,
-				BusinessID:    d.BusinessId,
-				CreatedAt:     d.CreatedAt.AsTime(),
-				Currency:      d.Currency,
-				DisputeID:     d.DisputeId,
-				DisputeStage:  d.DisputeStage,
-				DisputeStatus: d.DisputeStatus,
-				PaymentID:     d.PaymentId,
-				Remarks:       d.Remarks,
-			})
-		}
-
-		// Refunds
-		for _, rf := range in.PaymentPayload.Refunds {
-			requestPayload.Refunds = append(requestPayload.Refunds, struct {
-				Amount     int       `json:"amount"`
-				BusinessID string    `json:"business_id"`
-				CreatedAt  time.Time `json:"created_at"`
-				Currency   *string   `json:"currency"`
-				IsPartial  bool      `json:"is_partial"`
-				PaymentID  string    `json:"payment_id"`
-				Reason     string    `json:"reason"`
-				RefundID   string    `json:"refund_id"`
-				Status     string    `json:"status"`
-			}{
-				Amount:     int(rf.Amount),
-				BusinessID: rf.BusinessId,
-				CreatedAt:  rf.CreatedAt.AsTime(),
-				Currency:   &rf.Currency,
-				IsPartial:  rf.IsPartial,
-				PaymentID:  rf.PaymentId,
-				Reason:     rf.Reason,
-				RefundID:   rf.RefundId,
-				Status:     rf.Status,
-			})
+	// The booking moves first, see Payment_Service_Webhook_Producer
+	err := r.publish(ctx, func(ctx context.Context) error {
+		if id := saga.BookingID(ctx, in.GetPaymentPayload()); r.Saga != nil && id != "" {
+			if err := r.Saga.PaymentFailed(ctx, id, requestPayload.PaymentID, customer(requestPayload), requestPayload.ErrorMessage); err != nil {
+				return err
+			}
 		}
 
-		// Metadata (if provided as JSON string map)
-		// if in.PaymentPayload.Metadata != nil {
-		// 	requestPayload.Metadata = make(map[string]interface{})
-		// 	for key, val := range in.PaymentPayload.Metadata.Fields {
-		// 		// Simplified conversion
-		// 		requestPayload.Metadata[key] = val.GetStringValue()
-		// 	}
-		// }
-
-		// Send to RabbitMQ
-		err := r.Producer.Payment_Service_Failure_Producer(requestPayload)
-
-		done <- err
-	}()
-
-	select {
-	case <-ctx.Done():
-		fmt.Println("Timeout as request took more than 10 seconds")
-		return &rabbitmq_producer.Payment_Service_Producer_Response{
-			Error: ctx.Err().Error(),
-		}, ctx.Err()
-	case err := <-done:
-		if err != nil {
-			return nil, err
-		}
-	}
+		return r.Producer.Payment_Service_Failure_Producer(ctx, requestPayload)
+	})
 
 	return &rabbitmq_producer.Payment_Service_Producer_Response{
-		Error: "",
-	}, nil
+		Error: rpcerrors.Message(err),
+	}, err
 }
 
 func (r *Rabbitmq_Producer_Service) Cast_Service_Producer(ctx context.Context, in *rabbitmq_producer.Cast) (*rabbitmq_producer.Cast_Service_Producer_Response, error) {
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
-
 	var castInfo ExtendedCastAndCrew
 
 	castInfo.Name = in.Name
@@ -426,22 +180,16 @@ func (r *Rabbitmq_Producer_Service) Cast_Service_Producer(ctx context.Context, i
 	castInfo.Type = in.Type.String()
 	castInfo.StarpiCastUid = in.StarpiCastUidStr
 
-	fmt.Println("inside the cast service producer grpc method")
+	slog.DebugContext(ctx, "publishing cast", "movie_id", castInfo.MovieID, "strapi_cast_uid", castInfo.StarpiCastUid)
 
-	fmt.Printf("cast struct send to producer : %#v\n", castInfo)
+	err := r.publish(ctx, func(ctx context.Context) error {
+		return r.Producer.Add_Cast_Producer(ctx, castInfo)
+	})
 
-	go func() {
-		err := r.Producer.Add_Cast_Producer(castInfo)
-		done <- err
-	}()
-
-	select {
-	case err := <-done:
-		if err != nil {
-			return nil, err
-		}
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	if err != nil {
+		return &rabbitmq_producer.Cast_Service_Producer_Response{
+			Error: rpcerrors.Message(err),
+		}, err
 	}
 
 	return &rabbitmq_producer.Cast_Service_Producer_Response{
@@ -452,11 +200,6 @@ func (r *Rabbitmq_Producer_Service) Cast_Service_Producer(ctx context.Context, i
 
 func (r *Rabbitmq_Producer_Service) Delete_Cast_Producer(ctx context.Context, in *rabbitmq_producer.Cast) (*rabbitmq_producer.Cast_Service_Producer_Response, error) {
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
-
 	var castInfo ExtendedCastAndCrew
 
 	castInfo.Name = in.Name
@@ -467,22 +210,16 @@ func (r *Rabbitmq_Producer_Service) Delete_Cast_Producer(ctx context.Context, in
 	castInfo.StarpiCastUid = in.StarpiCastUidStr
 	castInfo.CastAndCrew.ID = uint(in.CastId)
 
-	fmt.Println("inside the delete cast service producer grpc method")
+	slog.DebugContext(ctx, "deleting cast", "cast_id", castInfo.ID, "strapi_cast_uid", castInfo.StarpiCastUid)
 
-	fmt.Printf("cast struct send to delete producer : %#v\n", castInfo)
-
-	go func() {
-		err := r.Producer.Delete_Cast_Producer(castInfo)
-		done <- err
-	}()
+	err := r.publish(ctx, func(ctx context.Context) error {
+		return r.Producer.Delete_Cast_Producer(ctx, castInfo)
+	})
 
-	select {
-	case err := <-done:
-		if err != nil {
-			return nil, err
-		}
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	if err != nil {
+		return &rabbitmq_producer.Cast_Service_Producer_Response{
+			Error: rpcerrors.Message(err),
+		}, err
 	}
 
 	return &rabbitmq_producer.Cast_Service_Producer_Response{
@@ -493,34 +230,17 @@ func (r *Rabbitmq_Producer_Service) Delete_Cast_Producer(ctx context.Context, in
 
 func (r *Rabbitmq_Producer_Service) Movie_Time_Slot_Producer(ctx context.Context, in *rabbitmq_producer.Movie_Time_Slot_Strapi) (*rabbitmq_producer.Movie_Time_Slot_Producer_Response, error) {
 
-	fmt.Println("inside the movie time slot producer grpc method")
-
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
-
 	var movieTimeSlotPayload MovieTimeSlotPayload
+	var violations validation.Violations
 
-	startTime, err := time.Parse(time.RFC3339, in.Starttime)
-
-	if err != nil {
-		fmt.Printf("an error occured while parsing start time %s", err.Error())
-		return nil, err
-	}
-
-	endtime, err := time.Parse(time.RFC3339, in.Endtime)
-
-	if err != nil {
-		fmt.Printf("an error occured while parsing end time %s", err.Error())
-		return nil, err
-	}
-
-	date, err := time.Parse("2006-01-02", in.Date)
+	startTime, _ := violations.Time("starttime", time.RFC3339, in.Starttime)
+	endtime, _ := violations.Time("endtime", time.RFC3339, in.Endtime)
+	date, _ := violations.Time("date", "2006-01-02", in.Date)
 
-	if err != nil {
-		fmt.Printf("an error occured while parsing date %s", err.Error())
-		return nil, err
+	if err := violations.Err(); err != nil {
+		return &rabbitmq_producer.Movie_Time_Slot_Producer_Response{
+			Error: rpcerrors.Message(err),
+		}, err
 	}
 
 	movieTimeSlotPayload.StartTime = startTime
@@ -542,19 +262,14 @@ func (r *Rabbitmq_Producer_Service) Movie_Time_Slot_Producer(ctx context.Context
 		movieTimeSlotPayload.MovieFormat = "UNKNOWN"
 	}
 
-	go func() {
-		err := r.Producer.Movie_Time_Slot_Producer(movieTimeSlotPayload)
-		done <- err
-	}()
+	err := r.publish(ctx, func(ctx context.Context) error {
+		return r.Producer.Movie_Time_Slot_Producer(ctx, movieTimeSlotPayload)
+	})
 
-	select {
-	case err := <-done:
-		if err != nil {
-			fmt.Printf("error occured while executing movie time slot producer")
-			return nil, err
-		}
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	if err != nil {
+		return &rabbitmq_producer.Movie_Time_Slot_Producer_Response{
+			Error: rpcerrors.Message(err),
+		}, err
 	}
 
 	return &rabbitmq_producer.Movie_Time_Slot_Producer_Response{
@@ -565,20 +280,15 @@ func (r *Rabbitmq_Producer_Service) Movie_Time_Slot_Producer(ctx context.Context
 
 func (r *Rabbitmq_Producer_Service) Movie_Producer(ctx context.Context, in *rabbitmq_producer.Movie_Strapi) (*rabbitmq_producer.Movie_Time_Slot_Producer_Response, error) {
 
-	fmt.Println("inside the movie producer grpc method")
-
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
-
 	var moviePayload MoviePayload
+	var violations validation.Violations
 
-	releaseDate, err := time.Parse("2006-01-02", in.ReleaseDate)
+	releaseDate, _ := violations.Time("release_date", "2006-01-02", in.ReleaseDate)
 
-	if err != nil {
-		fmt.Printf("an error occured while parsing release date %s", err.Error())
-		return nil, err
+	if err := violations.Err(); err != nil {
+		return &rabbitmq_producer.Movie_Time_Slot_Producer_Response{
+			Error: rpcerrors.Message(err),
+		}, err
 	}
 
 	moviePayload.Title = in.Title
@@ -596,19 +306,14 @@ func (r *Rabbitmq_Producer_Service) Movie_Producer(ctx context.Context, in *rabb
 	moviePayload.TrailerURL = in.TrailerUrl
 	moviePayload.StarpiMovieUid = in.StarpiMovieUid
 
-	go func() {
-		err := r.Producer.Movie_Producer(moviePayload)
-		done <- err
-	}()
+	err := r.publish(ctx, func(ctx context.Context) error {
+		return r.Producer.Movie_Producer(ctx, moviePayload)
+	})
 
-	select {
-	case err := <-done:
-		if err != nil {
-			fmt.Printf("error occured while executing movie producer")
-			return nil, err
-		}
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	if err != nil {
+		return &rabbitmq_producer.Movie_Time_Slot_Producer_Response{
+			Error: rpcerrors.Message(err),
+		}, err
 	}
 
 	return &rabbitmq_producer.Movie_Time_Slot_Producer_Response{
@@ -619,31 +324,19 @@ func (r *Rabbitmq_Producer_Service) Movie_Producer(ctx context.Context, in *rabb
 
 func (r *Rabbitmq_Producer_Service) Delete_Movie_Producer(ctx context.Context, in *rabbitmq_producer.Movie_Strapi) (*rabbitmq_producer.Movie_Time_Slot_Producer_Response, error) {
 
-	fmt.Println("inside the delete movie producer")
-
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
-
 	var moviePayload MoviePayload
 
 	moviePayload.StarpiMovieUid = in.StarpiMovieUid
 	moviePayload.ID = uint(in.MovieId)
 
-	go func() {
-		err := r.Producer.Delete_Movie_Producer(moviePayload)
-		done <- err
-	}()
+	err := r.publish(ctx, func(ctx context.Context) error {
+		return r.Producer.Delete_Movie_Producer(ctx, moviePayload)
+	})
 
-	select {
-	case err := <-done:
-		if err != nil {
-			fmt.Printf("error occured while executing delete movie producer")
-			return nil, err
-		}
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	if err != nil {
+		return &rabbitmq_producer.Movie_Time_Slot_Producer_Response{
+			Error: rpcerrors.Message(err),
+		}, err
 	}
 
 	return &rabbitmq_producer.Movie_Time_Slot_Producer_Response{
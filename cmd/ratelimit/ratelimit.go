@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/auth"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+)
+
+// Limiter applies the per-method and per-caller token buckets from the limits
+// configuration. Buckets pick up changed limits on the next call after a reload.
+type Limiter struct {
+	Config *config.Store
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	spec    string
+	limiter *rate.Limiter
+}
+
+func NewLimiter(store *config.Store) *Limiter {
+	return &Limiter{Config: store, buckets: map[string]*bucket{}}
+}
+
+// UnaryServerInterceptor rejects calls over their method or caller limit with
+// ResourceExhausted and a retry delay of when the next token is available.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method, ok := strings.CutPrefix(info.FullMethod, "/"+rabbitmq_producer.RabbitmqProducerService_ServiceDesc.ServiceName+"/")
+
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		if err := l.Allow(method, auth.FromContext(ctx)); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// Allow takes a token from the method's and the caller's bucket, or returns a
+// ResourceExhausted error when either is empty.
+func (l *Limiter) Allow(method string, caller auth.Identity) error {
+	limits := l.Config.Load().Limits
+
+	callerSpec, ok := limits.Callers[caller.Name]
+
+	if !ok {
+		callerSpec = limits.Callers["*"]
+	}
+
+	checks := []struct {
+		key, spec, what string
+	}{
+		{"method:" + method, limits.Methods[method], "rate limit for " + method},
+		{"caller:" + caller.String(), callerSpec, "rate limit for " + caller.String()},
+	}
+
+	var reservations []*rate.Reservation
+
+	for _, c := range checks {
+		lim := l.bucket(c.key, c.spec)
+
+		if lim == nil {
+			continue
+		}
+
+		r := lim.Reserve()
+
+		if delay := r.Delay(); delay > 0 {
+			r.Cancel()
+
+			// Give back the tokens already taken so a rejected call costs nothing
+			for _, taken := range reservations {
+				taken.Cancel()
+			}
+
+			return rpcerrors.Throttled(fmt.Errorf("%w: %s exceeded", rpcerrors.ErrBackpressure, c.what), delay.Round(time.Millisecond))
+		}
+
+		reservations = append(reservations, r)
+	}
+
+	return nil
+}
+
+// bucket returns the limiter for key, adjusting it when its spec changed. An
+// empty spec means the key is unlimited.
+func (l *Limiter) bucket(key, spec string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if spec == "" {
+		delete(l.buckets, key)
+		return nil
+	}
+
+	b, ok := l.buckets[key]
+
+	if ok && b.spec == spec {
+		return b.limiter
+	}
+
+	limit, err := config.ParseLimit(spec)
+
+	if err != nil {
+		// Validation rejects bad limits before they are stored, so this only
+		// guards against a store that skipped it
+		return nil
+	}
+
+	if ok {
+		b.limiter.SetLimit(rate.Limit(limit.PerSecond))
+		b.limiter.SetBurst(limit.Burst)
+		b.spec = spec
+
+		return b.limiter
+	}
+
+	b = &bucket{spec: spec, limiter: rate.NewLimiter(rate.Limit(limit.PerSecond), limit.Burst)}
+	l.buckets[key] = b
+
+	return b.limiter
+}
@@ -0,0 +1,265 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/audit"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// entityKeys are the payload fields that identify the entity an event is about,
+// looked up at the top level and inside the Strapi envelope's data.
+var entityKeys = []string{"payment_id", "strapi_cast_uid", "strapi_movie_uid", "movie_id", "venue_id"}
+
+// Delivered are the outcomes replayed by default. Other attempts never reached
+// consumers, and a successful retry of theirs is archived on its own.
+var Delivered = []string{audit.OutcomeConfirmed, audit.OutcomeSent}
+
+// Filter selects archived events. Zero fields match everything, except
+// Outcomes, which defaults to Delivered.
+type Filter struct {
+	Routes     []string
+	From, To   time.Time
+	EntityID   string
+	MessageIDs []string
+	Outcomes   []string
+}
+
+func (f Filter) outcomes() []string {
+	if len(f.Outcomes) == 0 {
+		return Delivered
+	}
+
+	return f.Outcomes
+}
+
+// Match reports whether r passes the filter.
+func (f Filter) Match(r audit.Record) bool {
+	if len(f.Routes) > 0 && !slices.Contains(f.Routes, r.Route) {
+		return false
+	}
+
+	if !f.From.IsZero() && r.PublishedAt.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && !r.PublishedAt.Before(f.To) {
+		return false
+	}
+
+	if len(f.MessageIDs) > 0 && !slices.Contains(f.MessageIDs, r.MessageID) {
+		return false
+	}
+
+	if !slices.Contains(f.outcomes(), r.Outcome) {
+		return false
+	}
+
+	if f.EntityID != "" && !slices.Contains(EntityIDs(r), f.EntityID) {
+		return false
+	}
+
+	return true
+}
+
+// EntityIDs returns the identifiers of the entity r is about: its correlation ID
+// and the entity fields of its payload.
+func EntityIDs(r audit.Record) []string {
+	var ids []string
+
+	if r.CorrelationID != "" {
+		ids = append(ids, r.CorrelationID)
+	}
+
+	if r.Key != "" {
+		ids = append(ids, r.Key)
+	}
+
+	var payload map[string]any
+
+	if json.Unmarshal(r.Payload, &payload) != nil {
+		return ids
+	}
+
+	for _, doc := range []any{payload, payload["data"]} {
+		fields, ok := doc.(map[string]any)
+
+		if !ok {
+			continue
+		}
+
+		for _, key := range entityKeys {
+			switch v := fields[key].(type) {
+			case string:
+				ids = append(ids, v)
+			case float64:
+				ids = append(ids, fmt.Sprint(v))
+			}
+		}
+	}
+
+	return ids
+}
+
+// ReadFiles returns the records in the audit files of dir that match f, oldest
+// first. Earlier replays are left out so they are not picked up again.
+func ReadFiles(dir string, f Filter) ([]audit.Record, error) {
+	files, err := audit.Files(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var records []audit.Record
+
+	for _, name := range files {
+		err := readFile(name, func(r audit.Record) {
+			if !r.Replay && f.Match(r) {
+				records = append(records, r)
+			}
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+func readFile(name string, fn func(audit.Record)) error {
+	file, err := os.Open(name)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		var r audit.Record
+
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+
+		fn(r)
+	}
+
+	return scanner.Err()
+}
+
+// ReadDatabase returns the records in the audit_records table that match f, like ReadFiles.
+func ReadDatabase(db *gorm.DB, f Filter) ([]audit.Record, error) {
+	query := db.Model(&audit.Record{}).
+		Where("replay = ?", false).
+		Where("outcome IN ?", f.outcomes()).
+		Order("published_at, id")
+
+	if len(f.Routes) > 0 {
+		query = query.Where("route IN ?", f.Routes)
+	}
+
+	if !f.From.IsZero() {
+		query = query.Where("published_at >= ?", f.From)
+	}
+
+	if !f.To.IsZero() {
+		query = query.Where("published_at < ?", f.To)
+	}
+
+	if len(f.MessageIDs) > 0 {
+		query = query.Where("message_id IN ?", f.MessageIDs)
+	}
+
+	var rows []audit.Record
+
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("reading audit table: %w", err)
+	}
+
+	// Entity IDs live in the payload, so they are matched here
+	var records []audit.Record
+
+	for _, r := range rows {
+		if f.Match(r) {
+			records = append(records, r)
+		}
+	}
+
+	return records, nil
+}
+
+// Publisher re-publishes one archived event.
+type Publisher interface {
+	Replay(ctx context.Context, r audit.Record) error
+}
+
+// Options control a replay run.
+type Options struct {
+	DryRun bool
+
+	// Rate caps re-publishes per second; zero means no limit.
+	Rate float64
+}
+
+// Result counts what a replay run did.
+type Result struct {
+	Published int
+	Skipped   int
+	Failed    int
+}
+
+// Run re-publishes records in order. Records without a payload, or whose payload
+// was redacted, cannot be rebuilt and are skipped. In a dry run every record
+// that would be published is only reported to fn.
+func Run(ctx context.Context, p Publisher, records []audit.Record, opts Options, fn func(r audit.Record, err error)) (Result, error) {
+	var result Result
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	}
+
+	for _, r := range records {
+		if len(r.Payload) == 0 || r.Redacted {
+			slog.Warn("skipping event without a replayable payload", "message_id", r.MessageID, "route", r.Route)
+			result.Skipped++
+			continue
+		}
+
+		if opts.DryRun {
+			fn(r, nil)
+			result.Published++
+			continue
+		}
+
+		if err := limiter.Wait(ctx); err != nil {
+			return result, err
+		}
+
+		err := p.Replay(ctx, r)
+
+		if err != nil {
+			result.Failed++
+		} else {
+			result.Published++
+		}
+
+		fn(r, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/audit"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/auth"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/database"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/logging"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/replay"
+	"gorm.io/gorm"
+)
+
+// runReplay implements "replay [flags] [-- configuration flags]": it re-publishes
+// archived events from the audit log through the producer.
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+
+	configPath := fs.String("config", "", "path to a YAML configuration file")
+	source := fs.String("source", "files", "where to read events from: files (audit.directory) or database (audit_records)")
+	dir := fs.String("dir", "", "audit directory to read, defaults to audit.directory")
+	routes := fs.String("route", "", "comma separated routes to replay, e.g. cast_creation,movie_creation")
+	from := fs.String("from", "", "replay events published at or after this RFC 3339 time")
+	to := fs.String("to", "", "replay events published before this RFC 3339 time")
+	entity := fs.String("entity", "", "replay events about this entity (payment ID or Strapi UID)")
+	messageIDs := fs.String("message-id", "", "comma separated message IDs to replay")
+	outcomes := fs.String("outcome", "", "comma separated outcomes to replay, defaults to confirmed,sent")
+	dryRun := fs.Bool("dry-run", false, "list the events that would be published without publishing them")
+	perSecond := fs.Float64("rate", 10, "events re-published per second, 0 for no limit")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+
+		return 2
+	}
+
+	configArgs := fs.Args()
+
+	if *configPath != "" {
+		configArgs = append([]string{"-config", *configPath}, configArgs...)
+	}
+
+	cfg, _, err := config.Load(configArgs)
+
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		return 2
+	}
+
+	store := config.NewStore(cfg)
+
+	logging.Setup(os.Stderr, store)
+
+	filter := replay.Filter{
+		Routes:     splitList(*routes),
+		EntityID:   *entity,
+		MessageIDs: splitList(*messageIDs),
+		Outcomes:   splitList(*outcomes),
+	}
+
+	for _, outcome := range filter.Outcomes {
+		if !slices.Contains(outcomeNames, outcome) {
+			slog.Error("unknown outcome", "outcome", outcome, "known", outcomeNames)
+			return 2
+		}
+	}
+
+	for _, t := range []struct {
+		flag  string
+		value string
+		into  *time.Time
+	}{{"from", *from, &filter.From}, {"to", *to, &filter.To}} {
+		if t.value == "" {
+			continue
+		}
+
+		if *t.into, err = time.Parse(time.RFC3339, t.value); err != nil {
+			slog.Error("invalid time", "flag", t.flag, "error", err)
+			return 2
+		}
+	}
+
+	routeConfig := cfg.Routes.All()
+
+	for _, name := range filter.Routes {
+		if _, ok := routeConfig[name]; !ok {
+			slog.Error("unknown route", "route", name)
+			return 2
+		}
+	}
+
+	var db *gorm.DB
+
+	if cfg.Database.Driver != "" {
+		if db, err = database.Open(cfg.Database); err != nil {
+			slog.Error("failed to open the database", "error", err)
+			return 1
+		}
+	}
+
+	var records []audit.Record
+
+	switch *source {
+	case "files":
+		if *dir == "" {
+			*dir = cfg.Audit.Directory
+		}
+
+		records, err = replay.ReadFiles(*dir, filter)
+	case "database":
+		if db == nil {
+			slog.Error("reading from the database needs database.driver")
+			return 2
+		}
+
+		records, err = replay.ReadDatabase(db, filter)
+	default:
+		slog.Error("unknown source, use files or database", "source", *source)
+		return 2
+	}
+
+	if err != nil {
+		slog.Error("failed to read archived events", "error", err)
+		return 1
+	}
+
+	slog.Info("archived events selected", "count", len(records), "dry_run", *dryRun)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ctx = auth.WithIdentity(ctx, auth.Identity{Name: "replay", Method: "cli"})
+
+	var producer producers.Producer
+
+	if !*dryRun {
+		backend, err := openBackend(store)
+
+		if err != nil {
+			slog.Error("failed to connect to the broker", "backend", cfg.Publisher.Backend, "error", err)
+			return 1
+		}
+
+		defer backend.Close()
+
+		producer = producers.Producer{Publisher: backend.Publisher, Config: store, Flow: backend.Flow}
+
+		// Replays are audited like any other publish
+		if cfg.Audit.Enabled {
+			if producer.Audit, err = audit.Open(store, db); err != nil {
+				slog.Error("failed to open the audit log", "error", err)
+				return 1
+			}
+
+			defer producer.Audit.Close()
+		}
+	}
+
+	result, err := replay.Run(ctx, &producer, records, replay.Options{DryRun: *dryRun, Rate: *perSecond}, func(r audit.Record, err error) {
+		status := "published"
+
+		switch {
+		case *dryRun:
+			status = "would publish"
+		case err != nil:
+			status = "failed: " + err.Error()
+		}
+
+		fmt.Printf("%s\t%s\t%s\t%s\n", r.PublishedAt.UTC().Format(time.RFC3339), r.Route, r.MessageID, status)
+	})
+
+	slog.Info("replay finished", "published", result.Published, "skipped", result.Skipped, "failed", result.Failed)
+
+	if err != nil {
+		slog.Error("replay interrupted", "error", err)
+		return 1
+	}
+
+	if result.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+var outcomeNames = []string{audit.OutcomeConfirmed, audit.OutcomeSent, audit.OutcomeNacked, audit.OutcomeRejected, audit.OutcomeFailed}
+
+func splitList(s string) []string {
+	var out []string
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,132 @@
+package rpcerrors
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var (
+	// ErrBackpressure is returned when the broker or a local limit asks callers to slow down.
+	ErrBackpressure = errors.New("publishing is being throttled")
+
+	// ErrBrokerUnavailable is returned when there is no usable broker connection.
+	ErrBrokerUnavailable = errors.New("message broker is unavailable")
+
+	// ErrNacked is returned when the broker negatively acknowledges a publish.
+	ErrNacked = errors.New("broker did not accept the message")
+
+	// ErrRouteDisabled is returned when operators switched a route off in the configuration.
+	ErrRouteDisabled = errors.New("route is disabled")
+)
+
+// RetryDelay is the back-off suggested to callers for errors that are worth retrying.
+const RetryDelay = time.Second
+
+// FromError converts err into a gRPC status error. Errors that already carry a
+// status (validation failures for example) are returned unchanged.
+func FromError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return withRetry(codes.DeadlineExceeded, err)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, ErrBackpressure):
+		return withRetry(codes.ResourceExhausted, err)
+	case errors.Is(err, ErrBrokerUnavailable), errors.Is(err, ErrNacked):
+		return withRetry(codes.Unavailable, err)
+	case errors.Is(err, ErrRouteDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	var amqpErr *amqp091.Error
+
+	if errors.As(err, &amqpErr) {
+		switch amqpErr.Code {
+		case amqp091.AccessRefused:
+			return status.Error(codes.PermissionDenied, err.Error())
+		case amqp091.NotFound, amqp091.PreconditionFailed:
+			return status.Error(codes.FailedPrecondition, err.Error())
+		case amqp091.ResourceLocked, amqp091.ResourceError:
+			return withRetry(codes.ResourceExhausted, err)
+		case amqp091.ContentTooLarge:
+			return status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return withRetry(codes.Unavailable, err)
+		}
+	}
+
+	var kafkaErr *kerr.Error
+
+	if errors.As(err, &kafkaErr) {
+		switch kafkaErr {
+		case kerr.TopicAuthorizationFailed, kerr.ClusterAuthorizationFailed, kerr.TransactionalIDAuthorizationFailed:
+			return status.Error(codes.PermissionDenied, err.Error())
+		case kerr.UnknownTopicOrPartition, kerr.InvalidTopicException, kerr.PolicyViolation:
+			return status.Error(codes.FailedPrecondition, err.Error())
+		case kerr.MessageTooLarge, kerr.RecordListTooLarge, kerr.InvalidRecord, kerr.CorruptMessage:
+			return status.Error(codes.InvalidArgument, err.Error())
+		case kerr.ThrottlingQuotaExceeded:
+			return withRetry(codes.ResourceExhausted, err)
+		default:
+			return withRetry(codes.Unavailable, err)
+		}
+	}
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) {
+		return withRetry(codes.Unavailable, err)
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Message returns the text legacy callers expect in the response error field,
+// the status message of err or "" when there is none.
+func Message(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return status.Convert(err).Message()
+}
+
+// Throttled returns a ResourceExhausted status that asks the caller to retry after delay.
+func Throttled(err error, delay time.Duration) error {
+	return withRetryAfter(codes.ResourceExhausted, err, delay)
+}
+
+func withRetry(code codes.Code, err error) error {
+	return withRetryAfter(code, err, RetryDelay)
+}
+
+func withRetryAfter(code codes.Code, err error, delay time.Duration) error {
+	st := status.New(code, err.Error())
+
+	detailed, detailErr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(delay),
+	})
+
+	if detailErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
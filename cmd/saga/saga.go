@@ -0,0 +1,495 @@
+// Package saga follows a booking from the seat lock through the payment to the
+// confirmation mail, and compensates by unlocking the seats and mailing the
+// customer when the payment fails or never arrives. Bookings are kept in the
+// database, so a restart resumes them where they stopped.
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/metrics"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Callers tie their RPCs to a booking with these gRPC headers. Payments may
+// carry the booking in their "booking_id" metadata instead.
+const (
+	BookingIDHeader = "x-booking-id"
+	EmailHeader     = "x-customer-email"
+	NameHeader      = "x-customer-name"
+)
+
+const (
+	AwaitingPayment  = "awaiting_payment"  // seats locked, waiting for the payment
+	Confirming       = "confirming"        // paid, the confirmation mail is pending
+	Confirmed        = "confirmed"         // done
+	ReleasingSeats   = "releasing_seats"   // payment failed or timed out, the seats are being unlocked
+	NotifyingFailure = "notifying_failure" // seats unlocked, the failure mail is pending
+	Cancelled        = "cancelled"         // done, compensated
+)
+
+// pending are the states with work left to do.
+var pending = []string{AwaitingPayment, Confirming, ReleasingSeats, NotifyingFailure}
+
+// Booking is one saga. Revision increases with every change, updates only
+// apply to the revision they were based on so concurrent instances never
+// move a booking twice.
+type Booking struct {
+	ID        string `gorm:"primaryKey"`
+	State     string `gorm:"not null;index"`
+	Seats     []int  `gorm:"serializer:json"`
+	Email     string
+	Name      string
+	PaymentID string
+	Reason    string // why the booking was cancelled
+	Attempts  int    // failed attempts at the pending step
+	LastError string
+	DueAt     time.Time `gorm:"index"` // when the payment times out or the pending step is retried
+	Revision  int       `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Booking) TableName() string {
+	return "booking_sagas"
+}
+
+// Customer is who the booking's mails go to.
+type Customer struct {
+	Email string
+	Name  string
+}
+
+// Publisher emits the events of the saga's steps; *producers.Producer implements it.
+type Publisher interface {
+	Lock_Seats(ctx context.Context, seatsIds []int) error
+	Unlock_Seats(ctx context.Context, seatsIds []int) error
+	Send_Mail_Producer(ctx context.Context, contactInfo *rabbitmq_producer.Send_Mail_Producer_Request) error
+}
+
+type Orchestrator struct {
+	Config    *config.Store
+	DB        *gorm.DB
+	Publisher Publisher
+}
+
+// Open migrates the booking_sagas table.
+func Open(store *config.Store, db *gorm.DB, publisher Publisher) (*Orchestrator, error) {
+	if err := db.AutoMigrate(&Booking{}); err != nil {
+		return nil, fmt.Errorf("migrating booking sagas: %w", err)
+	}
+
+	return &Orchestrator{Config: store, DB: db, Publisher: publisher}, nil
+}
+
+// BookingID returns the booking a call belongs to, from the gRPC headers or
+// else the payment's metadata.
+func BookingID(ctx context.Context, payment *rabbitmq_producer.Payment) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if ids := md.Get(BookingIDHeader); len(ids) > 0 && ids[0] != "" {
+		return ids[0]
+	}
+
+	return payment.GetMetadata()["booking_id"]
+}
+
+// CustomerFromContext returns the customer sent in the gRPC headers.
+func CustomerFromContext(ctx context.Context) Customer {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var c Customer
+
+	if v := md.Get(EmailHeader); len(v) > 0 {
+		c.Email = v[0]
+	}
+
+	if v := md.Get(NameHeader); len(v) > 0 {
+		c.Name = v[0]
+	}
+
+	return c
+}
+
+// Get returns the booking with id.
+func (o *Orchestrator) Get(ctx context.Context, id string) (Booking, error) {
+	var b Booking
+
+	err := o.DB.WithContext(ctx).First(&b, "id = ?", id).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return b, status.Errorf(codes.NotFound, "booking %s not found", id)
+	}
+
+	return b, err
+}
+
+// Start records the booking and locks its seats. Retrying with the same
+// seats locks them again; a booking that moved on cannot be started again.
+// When the lock fails the booking is forgotten if nothing was sent, and
+// otherwise released by Advance.
+func (o *Orchestrator) Start(ctx context.Context, id string, seats []int, customer Customer) error {
+	b := Booking{
+		ID:    id,
+		State: AwaitingPayment,
+		Seats: seats,
+		Email: customer.Email,
+		Name:  customer.Name,
+		DueAt: time.Now().Add(o.Config.Load().Saga.PaymentTimeout),
+	}
+
+	res := o.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&b)
+
+	if res.Error != nil {
+		return fmt.Errorf("recording booking %s: %w", id, res.Error)
+	}
+
+	created := res.RowsAffected == 1
+
+	if !created {
+		existing, err := o.Get(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if existing.State != AwaitingPayment {
+			return status.Errorf(codes.FailedPrecondition, "booking %s is already %s", id, existing.State)
+		}
+
+		if !slices.Equal(existing.Seats, seats) {
+			return status.Errorf(codes.FailedPrecondition, "booking %s holds other seats", id)
+		}
+	} else {
+		metrics.BookingTransitions.WithLabelValues(AwaitingPayment).Inc()
+	}
+
+	if err := o.Publisher.Lock_Seats(ctx, seats); err != nil {
+		if !created {
+			return err
+		}
+
+		// Nothing was locked, so the caller can retry from scratch
+		if notSent(err) {
+			o.DB.WithContext(context.WithoutCancel(ctx)).Where("id = ? AND revision = ?", id, b.Revision).Delete(&Booking{})
+			return err
+		}
+
+		// The lock may have reached the broker, so the seats are released to be safe
+		if _, moveErr := o.move(context.WithoutCancel(ctx), &b, ReleasingSeats, map[string]any{"reason": "seat lock failed"}); moveErr != nil {
+			slog.WarnContext(ctx, "failed to release the seats of a failed lock", "booking_id", id, "error", moveErr)
+		}
+
+		return err
+	}
+
+	slog.InfoContext(ctx, "booking started", "booking_id", id, "seats", seats)
+
+	return nil
+}
+
+// notSent reports whether err is known to have stopped a publish before it
+// reached the broker.
+func notSent(err error) bool {
+	return errors.Is(err, rpcerrors.ErrRouteDisabled) ||
+		errors.Is(err, rpcerrors.ErrBackpressure) ||
+		status.Code(err) == codes.InvalidArgument
+}
+
+// PaymentSucceeded confirms the booking and mails the customer. A payment for
+// a booking that was already cancelled is logged, it has to be refunded.
+func (o *Orchestrator) PaymentSucceeded(ctx context.Context, id, paymentID string, customer Customer) error {
+	b, err := o.Get(ctx, id)
+
+	// The payment event still goes out for bookings this service does not follow
+	if status.Code(err) == codes.NotFound {
+		slog.WarnContext(ctx, "payment for an unknown booking", "booking_id", id, "payment_id", paymentID)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch b.State {
+	case AwaitingPayment:
+		fields := map[string]any{"payment_id": paymentID}
+		customerFields(fields, customer)
+
+		moved, err := o.move(ctx, &b, Confirming, fields)
+
+		if err != nil {
+			return err
+		}
+
+		// Changed under us, look again
+		if !moved {
+			return o.PaymentSucceeded(ctx, id, paymentID, customer)
+		}
+	case Confirming, Confirmed:
+		if b.PaymentID != paymentID {
+			slog.WarnContext(ctx, "second payment for a paid booking", "booking_id", id, "payment_id", paymentID, "paid_with", b.PaymentID)
+		}
+
+		return nil
+	default:
+		slog.WarnContext(ctx, "payment for a cancelled booking needs a refund", "booking_id", id, "payment_id", paymentID, "state", b.State, "reason", b.Reason)
+		return nil
+	}
+
+	o.steps(ctx, &b)
+
+	return nil
+}
+
+// PaymentFailed cancels the booking, unlocking the seats and mailing the customer.
+func (o *Orchestrator) PaymentFailed(ctx context.Context, id, paymentID string, customer Customer, reason string) error {
+	b, err := o.Get(ctx, id)
+
+	if status.Code(err) == codes.NotFound {
+		slog.WarnContext(ctx, "payment failure for an unknown booking", "booking_id", id, "payment_id", paymentID)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// A repeated failure, the booking is already being cancelled
+	if b.State != AwaitingPayment && b.PaymentID == paymentID {
+		return nil
+	}
+
+	if b.State != AwaitingPayment {
+		slog.WarnContext(ctx, "ignoring a payment failure", "booking_id", id, "payment_id", paymentID, "state", b.State)
+		return nil
+	}
+
+	if reason == "" {
+		reason = "payment failed"
+	}
+
+	fields := map[string]any{"payment_id": paymentID, "reason": reason}
+	customerFields(fields, customer)
+
+	moved, err := o.move(ctx, &b, ReleasingSeats, fields)
+
+	if err != nil {
+		return err
+	}
+
+	if !moved {
+		return o.PaymentFailed(ctx, id, paymentID, customer, reason)
+	}
+
+	o.steps(ctx, &b)
+
+	return nil
+}
+
+func customerFields(fields map[string]any, c Customer) {
+	if c.Email != "" {
+		fields["email"] = c.Email
+	}
+
+	if c.Name != "" {
+		fields["name"] = c.Name
+	}
+}
+
+// Run cancels unpaid bookings once their payment times out and retries failed
+// steps, until ctx is cancelled.
+func (o *Orchestrator) Run(ctx context.Context) {
+	for {
+		if err := o.Advance(ctx); err != nil {
+			slog.Warn("failed to advance bookings", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(o.Config.Load().Saga.RetryInterval):
+		}
+	}
+}
+
+// Advance moves on every booking that is due.
+func (o *Orchestrator) Advance(ctx context.Context) error {
+	var due []Booking
+
+	err := o.DB.WithContext(ctx).
+		Where("state IN ? AND due_at <= ?", pending, time.Now()).
+		Order("due_at").Limit(100).Find(&due).Error
+
+	if err != nil {
+		return fmt.Errorf("listing due bookings: %w", err)
+	}
+
+	var errs []error
+
+	for _, b := range due {
+		var moved bool
+
+		if b.State == AwaitingPayment {
+			moved, err = o.move(ctx, &b, ReleasingSeats, map[string]any{"reason": "payment timed out"})
+		} else {
+			// Claim the step so other instances leave it alone until it is due again
+			moved, err = o.update(ctx, &b, map[string]any{"due_at": o.retryAt()})
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if moved {
+			o.steps(ctx, &b)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// steps runs the pending steps of b until it is done or a step fails. A
+// failed step is retried by Run.
+func (o *Orchestrator) steps(ctx context.Context, b *Booking) {
+	for {
+		var (
+			next string
+			err  error
+		)
+
+		switch b.State {
+		case Confirming:
+			next, err = Confirmed, o.mail(ctx, b, confirmationMail(*b))
+		case ReleasingSeats:
+			next, err = NotifyingFailure, o.Publisher.Unlock_Seats(ctx, b.Seats)
+		case NotifyingFailure:
+			next, err = Cancelled, o.mail(ctx, b, failureMail(*b))
+		default:
+			return
+		}
+
+		if err != nil {
+			o.fail(ctx, b, err)
+			return
+		}
+
+		if moved, err := o.move(ctx, b, next, nil); err != nil || !moved {
+			if err != nil {
+				slog.WarnContext(ctx, "failed to record a booking step", "booking_id", b.ID, "state", next, "error", err)
+			}
+
+			return
+		}
+	}
+}
+
+func (o *Orchestrator) mail(ctx context.Context, b *Booking, mail *rabbitmq_producer.Send_Mail_Producer_Request) error {
+	if b.Email == "" {
+		slog.WarnContext(ctx, "no email to notify the customer", "booking_id", b.ID, "state", b.State)
+		return nil
+	}
+
+	return o.Publisher.Send_Mail_Producer(ctx, mail)
+}
+
+// fail records a failed step; it is retried once due again.
+func (o *Orchestrator) fail(ctx context.Context, b *Booking, err error) {
+	metrics.BookingStepFailures.WithLabelValues(b.State).Inc()
+
+	slog.WarnContext(ctx, "booking step failed", "booking_id", b.ID, "state", b.State, "attempts", b.Attempts+1, "error", err)
+
+	// Record it even when the caller gave up, the step is retried regardless
+	_, err = o.update(context.WithoutCancel(ctx), b, map[string]any{
+		"attempts":   b.Attempts + 1,
+		"last_error": err.Error(),
+		"due_at":     o.retryAt(),
+	})
+
+	if err != nil {
+		slog.WarnContext(ctx, "failed to record a failed booking step", "booking_id", b.ID, "error", err)
+	}
+}
+
+// move puts b into state to, along with fields. The step of the new state is
+// claimed until the retry interval passes.
+func (o *Orchestrator) move(ctx context.Context, b *Booking, to string, fields map[string]any) (bool, error) {
+	from := b.State
+
+	if fields == nil {
+		fields = map[string]any{}
+	}
+
+	fields["state"] = to
+	fields["attempts"] = 0
+	fields["last_error"] = ""
+	fields["due_at"] = o.retryAt()
+
+	moved, err := o.update(ctx, b, fields)
+
+	if moved {
+		metrics.BookingTransitions.WithLabelValues(to).Inc()
+		slog.InfoContext(ctx, "booking moved", "booking_id", b.ID, "from", from, "to", to)
+	}
+
+	return moved, err
+}
+
+// update applies fields to b unless it changed since it was read, and reloads it.
+func (o *Orchestrator) update(ctx context.Context, b *Booking, fields map[string]any) (bool, error) {
+	fields["revision"] = b.Revision + 1
+
+	res := o.DB.WithContext(ctx).Model(&Booking{}).
+		Where("id = ? AND revision = ?", b.ID, b.Revision).
+		Updates(fields)
+
+	if res.Error != nil {
+		return false, fmt.Errorf("updating booking %s: %w", b.ID, res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := o.DB.WithContext(ctx).First(b, "id = ?", b.ID).Error; err != nil {
+		return true, fmt.Errorf("reloading booking %s: %w", b.ID, err)
+	}
+
+	return true, nil
+}
+
+func (o *Orchestrator) retryAt() time.Time {
+	return time.Now().Add(o.Config.Load().Saga.RetryInterval)
+}
+
+func confirmationMail(b Booking) *rabbitmq_producer.Send_Mail_Producer_Request {
+	return &rabbitmq_producer.Send_Mail_Producer_Request{
+		To:       b.Email,
+		Name:     b.Name,
+		Category: "booking_confirmation",
+		Subject:  "Your booking is confirmed",
+		Text:     fmt.Sprintf("Your seats %v are booked. Booking %s, payment %s.", b.Seats, b.ID, b.PaymentID),
+	}
+}
+
+func failureMail(b Booking) *rabbitmq_producer.Send_Mail_Producer_Request {
+	return &rabbitmq_producer.Send_Mail_Producer_Request{
+		To:       b.Email,
+		Name:     b.Name,
+		Category: "booking_failed",
+		Subject:  "Your booking could not be completed",
+		Text:     fmt.Sprintf("Booking %s was cancelled (%s) and seats %v were released.", b.ID, b.Reason, b.Seats),
+	}
+}
@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/audit"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/auth"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/database"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+)
+
+func readAudit(t *testing.T, dir string) []audit.Record {
+	t.Helper()
+
+	files, err := audit.Files(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []audit.Record
+
+	for _, name := range files {
+		f, err := os.Open(name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scanner := bufio.NewScanner(f)
+
+		for scanner.Scan() {
+			var r audit.Record
+
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				t.Fatal(err)
+			}
+
+			records = append(records, r)
+		}
+
+		f.Close()
+	}
+
+	return records
+}
+
+func Test_audit(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg := config.Default()
+	cfg.Audit.Enabled = true
+	cfg.Audit.Directory = filepath.Join(dir, "audit")
+	cfg.Audit.Database = true
+	cfg.Database = config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(dir, "producer.db")}
+
+	db, err := database.Open(cfg.Database)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := config.NewStore(cfg)
+	log, err := audit.Open(store, db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer log.Close()
+
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Name: "payments", Method: "apikey"})
+
+	payment := []byte(`{"payment_id":"pay_1","customer":{"email":"jane@example.com","name":"Jane"},"total_amount":1200}`)
+	movie := []byte(`{"action":"create","model":"movie","data":{"name":"Dune"}}`)
+
+	now := time.Now()
+
+	log.Record(ctx, audit.Record{PublishedAt: now, CompletedAt: now, MessageID: "m-1", Route: "payment_success", Outcome: audit.OutcomeConfirmed}, payment)
+	log.Record(ctx, audit.Record{PublishedAt: now, CompletedAt: now, MessageID: "m-2", Route: "movie_creation", Outcome: audit.OutcomeNacked, Error: "nacked"}, movie)
+
+	t.Run("Every publish is appended with its caller and payload hash", func(t *testing.T) {
+		records := readAudit(t, cfg.Audit.Directory)
+
+		if len(records) != 2 {
+			t.Fatalf("expected 2 records, got %d", len(records))
+		}
+
+		sum := sha256.Sum256(payment)
+
+		if r := records[0]; r.MessageID != "m-1" || r.Caller != "apikey:payments" || r.PayloadSHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("unexpected record %+v", r)
+		}
+
+		if r := records[1]; r.Outcome != audit.OutcomeNacked || r.Redacted || string(r.Payload) != string(movie) {
+			t.Errorf("expected the movie payload verbatim, got %+v", r)
+		}
+	})
+
+	t.Run("Payloads of customer routes are redacted", func(t *testing.T) {
+		r := readAudit(t, cfg.Audit.Directory)[0]
+
+		if !r.Redacted || strings.Contains(string(r.Payload), "jane@example.com") || strings.Contains(string(r.Payload), "Jane") {
+			t.Fatalf("expected PII to be masked, got %s", r.Payload)
+		}
+
+		if !strings.Contains(string(r.Payload), "pay_1") {
+			t.Fatalf("expected the payment id to be kept, got %s", r.Payload)
+		}
+	})
+
+	t.Run("Records are stored in the database too", func(t *testing.T) {
+		var records []audit.Record
+
+		if err := db.Order("id").Find(&records).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if len(records) != 2 || records[1].MessageID != "m-2" || records[1].Error != "nacked" {
+			t.Fatalf("unexpected rows %+v", records)
+		}
+	})
+
+	t.Run("Prune drops files and rows past the retention", func(t *testing.T) {
+		old := time.Now().Add(-100 * 24 * time.Hour)
+		stale := filepath.Join(cfg.Audit.Directory, "audit-2020-01-01T00-00-00.000000.jsonl")
+
+		if err := os.WriteFile(stale, []byte("{}\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		os.Chtimes(stale, old, old)
+
+		log.Record(ctx, audit.Record{PublishedAt: old, MessageID: "m-old", Route: "lock_seats", Outcome: audit.OutcomeConfirmed}, []byte(`[1,2]`))
+
+		if err := log.Prune(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(stale); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", stale)
+		}
+
+		var count int64
+		db.Model(&audit.Record{}).Count(&count)
+
+		if count != 2 {
+			t.Errorf("expected 2 rows after pruning, got %d", count)
+		}
+
+		if files, _ := audit.Files(cfg.Audit.Directory); len(files) != 1 {
+			t.Errorf("expected the current file to be kept, got %v", files)
+		}
+	})
+
+	t.Run("Outcomes follow the publish error", func(t *testing.T) {
+		cases := map[string]error{
+			audit.OutcomeConfirmed: nil,
+			audit.OutcomeNacked:    fmt.Errorf("%w: x", rpcerrors.ErrNacked),
+			audit.OutcomeRejected:  fmt.Errorf("%w: x", rpcerrors.ErrRouteDisabled),
+			audit.OutcomeFailed:    errors.New("publish failed"),
+		}
+
+		for want, err := range cases {
+			if got := audit.Outcome(err, true); got != want {
+				t.Errorf("%v: expected %s, got %s", err, want, got)
+			}
+		}
+
+		if got := audit.Outcome(nil, false); got != audit.OutcomeSent {
+			t.Errorf("expected sent without confirms, got %s", got)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/auth"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func Test_authorization(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, _ := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: "cms-1", Algorithm: string(jose.ES256), Use: "sig"},
+	}})
+
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	writeFile(t, jwksPath, jwks)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", "cms-1"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := func(t *testing.T, subject string, expiry time.Time) string {
+		raw, err := jwt.Signed(signer).Claims(jwt.Claims{
+			Subject:  subject,
+			Issuer:   "booking-auth",
+			Audience: jwt.Audience{"rabbitmq-producer"},
+			Expiry:   jwt.NewNumericDate(expiry),
+		}).Serialize()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return raw
+	}
+
+	cfg := config.Default()
+	cfg.Auth = config.AuthConfig{
+		Enforce:     true,
+		APIKeys:     map[string]string{"payment-gateway": "pg-key"},
+		JWKSFile:    jwksPath,
+		Issuer:      "booking-auth",
+		Audience:    "rabbitmq-producer",
+		CallerClaim: "sub",
+		Policy: map[string][]string{
+			"payment-gateway": {"Payment_Service_Webhook_Producer", "Payment_Service_Failure_Producer"},
+			"cms-bridge":      {"Cast_Service_Producer", "Movie_Producer"},
+		},
+	}
+
+	store := config.NewStore(cfg)
+	interceptor := auth.NewAuthorizer(store).UnaryServerInterceptor()
+
+	call := func(method string, md ...string) (auth.Identity, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(md...))
+		var seen auth.Identity
+
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+			seen = auth.FromContext(ctx)
+			return nil, nil
+		})
+
+		return seen, err
+	}
+
+	t.Run("API key callers reach the methods they are granted", func(t *testing.T) {
+		id, err := call(rabbitmq_producer.RabbitmqProducerService_Payment_Service_Webhook_Producer_FullMethodName, "x-api-key", "pg-key")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id.String() != "apikey:payment-gateway" {
+			t.Fatalf("unexpected identity %s", id)
+		}
+
+		_, err = call(rabbitmq_producer.RabbitmqProducerService_Unlock_Seats_FullMethodName, "x-api-key", "pg-key")
+
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected PermissionDenied, got %v", err)
+		}
+	})
+
+	t.Run("Bearer tokens are verified against the JWKS", func(t *testing.T) {
+		id, err := call(rabbitmq_producer.RabbitmqProducerService_Cast_Service_Producer_FullMethodName, "authorization", "Bearer "+token(t, "cms-bridge", time.Now().Add(time.Hour)))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id.String() != "jwt:cms-bridge" {
+			t.Fatalf("unexpected identity %s", id)
+		}
+
+		_, err = call(rabbitmq_producer.RabbitmqProducerService_Cast_Service_Producer_FullMethodName, "authorization", "Bearer "+token(t, "cms-bridge", time.Now().Add(-time.Hour)))
+
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected expired token to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("Anonymous and unknown callers are rejected", func(t *testing.T) {
+		_, err := call(rabbitmq_producer.RabbitmqProducerService_Lock_Seats_FullMethodName)
+
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected Unauthenticated, got %v", err)
+		}
+
+		_, err = call(rabbitmq_producer.RabbitmqProducerService_Lock_Seats_FullMethodName, "x-api-key", "guess")
+
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected Unauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("Policy changes apply on reload", func(t *testing.T) {
+		next := config.Clone(store.Load())
+		next.Auth.Enforce = false
+		store.Reload(next)
+
+		if _, err := call(rabbitmq_producer.RabbitmqProducerService_Lock_Seats_FullMethodName); err != nil {
+			t.Fatalf("expected the call through without enforcement, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_config(t *testing.T) {
+
+	t.Run("Defaults keep the historical topology", func(t *testing.T) {
+		cfg, _, err := config.Load(nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.Server.ListenAddress != ":1105" {
+			t.Fatalf("unexpected listen address %q", cfg.Server.ListenAddress)
+		}
+
+		if cfg.Routes.PaymentSuccess.Binding() != "payment_service_success" || cfg.Routes.PaymentSuccess.RoutingKey != "payment_service_success" {
+			t.Fatalf("payment success route changed: %+v", cfg.Routes.PaymentSuccess)
+		}
+
+		if len(cfg.Routes.All()) != 10 {
+			t.Fatalf("expected 10 routes, got %d", len(cfg.Routes.All()))
+		}
+	})
+
+	t.Run("Flags override env which overrides the file", func(t *testing.T) {
+		path := writeConfig(t, `
+server:
+  listen_address: ":7000"
+  default_timeout: 4s
+routes:
+  lock_seats:
+    exchange: file_exchange
+`)
+
+		t.Setenv("PRODUCER_SERVER_LISTEN_ADDRESS", ":8000")
+		t.Setenv("PRODUCER_ROUTES_LOCK_SEATS_EXCHANGE", "env_exchange")
+		t.Setenv("PRODUCER_SERVER_TIMEOUTS", "Lock_Seats=2s")
+
+		cfg, _, err := config.Load([]string{"-config", path, "-server.listen_address", ":9000"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.Server.ListenAddress != ":9000" {
+			t.Fatalf("expected flag to win, got %q", cfg.Server.ListenAddress)
+		}
+
+		if cfg.Routes.LockSeats.Exchange != "env_exchange" {
+			t.Fatalf("expected env to win over file, got %q", cfg.Routes.LockSeats.Exchange)
+		}
+
+		if cfg.Routes.LockSeats.Queue != "lock_seats_queue" {
+			t.Fatalf("expected untouched keys to keep defaults, got %q", cfg.Routes.LockSeats.Queue)
+		}
+
+		if cfg.Server.DefaultTimeout != 4*time.Second {
+			t.Fatalf("expected file timeout, got %s", cfg.Server.DefaultTimeout)
+		}
+
+		if d := cfg.MethodTimeout("/rabbitmq_producer_service.rabbitmqProducerService/Lock_Seats"); d != 2*time.Second {
+			t.Fatalf("unexpected Lock_Seats timeout %s", d)
+		}
+
+		if d := cfg.MethodTimeout("/rabbitmq_producer_service.rabbitmqProducerService/Unlock_Seats"); d != 4*time.Second {
+			t.Fatalf("unexpected Unlock_Seats timeout %s", d)
+		}
+	})
+
+	t.Run("Invalid settings are rejected", func(t *testing.T) {
+		path := writeConfig(t, `
+broker:
+  urls: ["http://example.com"]
+server:
+  timeouts:
+    Not_A_Method: 1s
+`)
+
+		_, _, err := config.Load([]string{"-config", path})
+
+		if err == nil {
+			t.Fatal("expected validation error")
+		}
+
+		for _, want := range []string{"broker.urls", "Not_A_Method"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in %v", want, err)
+			}
+		}
+	})
+
+	t.Run("External auth needs amqps and a client certificate", func(t *testing.T) {
+		path := writeConfig(t, `
+broker:
+  urls: ["amqp://rabbitmq:5672/"]
+  auth_mechanism: external
+  password: secret
+  password_file: /run/secrets/broker_password
+`)
+
+		_, _, err := config.Load([]string{"-config", path})
+
+		if err == nil {
+			t.Fatal("expected validation error")
+		}
+
+		for _, want := range []string{"amqps://", "cert_file", "password_file"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in %v", want, err)
+			}
+		}
+	})
+
+	t.Run("Broker credentials are read from files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "username"), []byte("producer\n"))
+		writeFile(t, filepath.Join(dir, "password"), []byte(" s3cret \n"))
+
+		t.Setenv("PRODUCER_BROKER_USERNAME_FILE", filepath.Join(dir, "username"))
+		t.Setenv("PRODUCER_BROKER_PASSWORD_FILE", filepath.Join(dir, "password"))
+
+		cfg, _, err := config.Load(nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		username, password, err := cfg.Broker.Credentials()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if username != "producer" || password != " s3cret " {
+			t.Fatalf("unexpected credentials %q/%q", username, password)
+		}
+	})
+
+	t.Run("Unknown keys in the file are rejected", func(t *testing.T) {
+		path := writeConfig(t, "server:\n  listen_adress: \":1\"\n")
+
+		if _, _, err := config.Load([]string{"-config", path}); err == nil {
+			t.Fatal("expected an error for the misspelled key")
+		}
+	})
+}
+
+func Test_config_reload(t *testing.T) {
+
+	t.Run("Only reloadable settings are applied", func(t *testing.T) {
+		store := config.NewStore(config.Default())
+
+		var notified *config.Config
+
+		store.Subscribe(func(previous, next *config.Config) {
+			notified = next
+		})
+
+		next := config.Default()
+		next.Server.DefaultTimeout = 3 * time.Second
+		next.Routes.LockSeats.Enabled = false
+		next.Server.ListenAddress = ":2000"
+		next.Broker.Password = "hunter2"
+
+		changes := store.Reload(next)
+
+		if len(changes) != 4 {
+			t.Fatalf("expected 4 changes, got %v", changes)
+		}
+
+		for _, c := range changes {
+			if strings.Contains(c.String(), "hunter2") {
+				t.Fatalf("secret leaked in diff: %s", c)
+			}
+
+			wantApplied := c.Path == "server.default_timeout" || c.Path == "routes.lock_seats.enabled"
+
+			if c.Applied != wantApplied {
+				t.Errorf("unexpected applied flag for %s", c)
+			}
+		}
+
+		current := store.Load()
+
+		if current.Server.DefaultTimeout != 3*time.Second || current.Routes.LockSeats.Enabled {
+			t.Fatalf("reloadable settings were not applied: %+v", current.Server)
+		}
+
+		if current.Server.ListenAddress != ":1105" || current.Broker.Password != "" {
+			t.Fatalf("restart-only settings must not change at runtime")
+		}
+
+		if notified != current {
+			t.Fatalf("subscribers were not notified with the new snapshot")
+		}
+	})
+
+	t.Run("Watcher picks up file changes", func(t *testing.T) {
+		path := writeConfig(t, "server:\n  default_timeout: 5s\n")
+		args := []string{"-config", path}
+
+		cfg, _, err := config.Load(args)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store := config.NewStore(cfg)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go config.Watch(ctx, store, args, path)
+
+		time.Sleep(100 * time.Millisecond)
+
+		if err := os.WriteFile(path, []byte("server:\n  default_timeout: 7s\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(3 * time.Second)
+
+		for store.Load().Server.DefaultTimeout != 7*time.Second {
+			if time.Now().After(deadline) {
+				t.Fatalf("reload not applied, timeout is %s", store.Load().Server.DefaultTimeout)
+			}
+
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+}
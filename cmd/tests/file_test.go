@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+)
+
+// readEvents decodes the JSON lines in data.
+func readEvents(t *testing.T, data []byte) []broker.Event {
+	t.Helper()
+
+	var events []broker.Event
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		var e broker.Event
+
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("decoding %s: %v", scanner.Text(), err)
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
+
+func Test_file(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("The service writes each route to its own file", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "events")
+
+		cfg := config.Default()
+		cfg.Publisher.Backend = "file"
+		cfg.File.Directory = dir
+		store := config.NewStore(cfg)
+
+		file := broker.NewFile(store)
+		defer file.Close()
+
+		client := serve(t, store, file)
+
+		for _, seats := range [][]int32{{1, 2}, {3}} {
+			if _, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: seats}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := client.Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{Title: "Dune", ReleaseDate: "2024-03-01", Duration: 166, StarpiMovieUid: "movie-uid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "lock_seats.jsonl"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		locks := readEvents(t, data)
+
+		if len(locks) != 2 || string(locks[0].Body) != "[1,2]" || locks[1].Exchange != "lock_seats" || locks[1].MessageID == "" {
+			t.Fatalf("unexpected lock events %+v", locks)
+		}
+
+		data, err = os.ReadFile(filepath.Join(dir, "movie_creation.jsonl"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var movie strapiMessage
+
+		if movies := readEvents(t, data); len(movies) != 1 || json.Unmarshal(movies[0].Body, &movie) != nil || movie.Model != "movie" {
+			t.Fatalf("unexpected movie events %+v", movies)
+		}
+	})
+
+	t.Run("With - events go to stdout", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.File.Directory = "-"
+		store := config.NewStore(cfg)
+
+		var stdout bytes.Buffer
+
+		file := broker.NewFile(store)
+		file.Stdout = &stdout
+
+		if err := producers.NewProducer(file, store).Unlock_Seats(ctx, []int{4}); err != nil {
+			t.Fatal(err)
+		}
+
+		file.Publish(ctx, cfg.Routes.UnlockSeats, broker.Message{Route: "unlock_seats", Body: []byte("not json")})
+
+		events := readEvents(t, stdout.Bytes())
+
+		if len(events) != 2 || events[0].Route != "unlock_seats" || string(events[1].Body) != `"not json"` {
+			t.Fatalf("unexpected events %+v", events)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/health"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func Test_health(t *testing.T) {
+	store := config.NewStore(config.Default())
+	monitor := health.NewMonitor(store)
+
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, monitor.Server())
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	check := func(t *testing.T, service string) healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res.Status
+	}
+
+	t.Run("Everything serves while the broker is healthy", func(t *testing.T) {
+		for _, service := range []string{"", "rabbitmq_producer_service.rabbitmqProducerService", "seats", "strapi"} {
+			if s := check(t, service); s != healthpb.HealthCheckResponse_SERVING {
+				t.Errorf("%q is %s", service, s)
+			}
+		}
+	})
+
+	t.Run("Failing publishes only take their own service down", func(t *testing.T) {
+		for i := 0; i < health.FailureThreshold; i++ {
+			monitor.Observe("lock_seats", errors.New("channel/connection is not open"))
+		}
+
+		monitor.Observe("movie_creation", context.DeadlineExceeded)
+
+		if s := check(t, "seats"); s != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Fatalf("expected seats NOT_SERVING, got %s", s)
+		}
+
+		if s := check(t, "strapi"); s != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("expected strapi SERVING, got %s", s)
+		}
+
+		monitor.Observe("unlock_seats", rpcerrors.ErrRouteDisabled)
+		monitor.Observe("lock_seats", nil)
+
+		if s := check(t, "seats"); s != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("expected seats to recover after a successful publish, got %s", s)
+		}
+	})
+
+	t.Run("Services with every route disabled do not serve", func(t *testing.T) {
+		next := config.Clone(store.Load())
+		next.Routes.SendMail.Enabled = false
+		store.Reload(next)
+
+		if s := monitor.Statuses()["mail"]; s != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Fatalf("expected mail NOT_SERVING, got %s", s)
+		}
+	})
+
+	t.Run("Flow control takes services out of rotation but keeps the process alive", func(t *testing.T) {
+		monitor.Blocked = func() (string, bool) { return "low on memory", true }
+		defer func() { monitor.Blocked = nil }()
+
+		statuses := monitor.Statuses()
+
+		if s := statuses[""]; s != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("expected the overall status to stay SERVING while blocked, got %s", s)
+		}
+
+		for _, service := range []string{rabbitmq_producer.RabbitmqProducerService_ServiceDesc.ServiceName, "payments", "seats"} {
+			if s := statuses[service]; s != healthpb.HealthCheckResponse_NOT_SERVING {
+				t.Errorf("expected %q NOT_SERVING while blocked, got %s", service, s)
+			}
+		}
+	})
+
+	t.Run("A dead broker takes everything down", func(t *testing.T) {
+		monitor.BrokerDown(errors.New("channel closed"))
+
+		for _, service := range []string{"", "payments", "strapi"} {
+			if s := check(t, service); s != healthpb.HealthCheckResponse_NOT_SERVING {
+				t.Errorf("%q is %s", service, s)
+			}
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/metrics"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// startNATS runs an embedded JetStream enabled server for the test.
+func startNATS(t *testing.T) *server.Server {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir()})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Start()
+	t.Cleanup(srv.Shutdown)
+
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server did not start")
+	}
+
+	return srv
+}
+
+func Test_jetstream(t *testing.T) {
+	srv := startNATS(t)
+
+	cfg := config.Default()
+	cfg.Publisher.Backend = "nats"
+	cfg.NATS.URLs = []string{srv.ClientURL()}
+	cfg.NATS.Stream = "BOOKING"
+	cfg.NATS.Subjects = map[string]string{"send_mail": "mail.send"}
+
+	store := config.NewStore(cfg)
+
+	nc, err := broker.ConnectNATS(cfg.NATS)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer nc.Close()
+
+	publisher, err := broker.NewJetStream(nc, store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js, _ := jetstream.New(nc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	producer := producers.NewProducer(publisher, store)
+
+	t.Run("Routes map to subjects captured by the declared stream", func(t *testing.T) {
+		if err := producer.Lock_Seats(ctx, []int{1, 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := producer.Send_Mail_Producer(ctx, &rabbitmq_producer.Send_Mail_Producer_Request{To: "jane@example.com", Subject: "Your tickets", Text: "Enjoy"}); err != nil {
+			t.Fatal(err)
+		}
+
+		stream, err := js.Stream(ctx, "BOOKING")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stream.CachedInfo().Config.Duplicates != 2*time.Minute {
+			t.Errorf("expected a 2m duplicate window, got %s", stream.CachedInfo().Config.Duplicates)
+		}
+
+		lock, err := stream.GetLastMsgForSubject(ctx, "lock_seats.lock_seats_key")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(lock.Data) != "[1,2]" || lock.Header.Get("Content-Type") != "application/json" || lock.Header.Get(nats.MsgIdHdr) == "" {
+			t.Errorf("unexpected message %s %v", lock.Data, lock.Header)
+		}
+
+		if _, err := stream.GetLastMsgForSubject(ctx, "mail.send"); err != nil {
+			t.Errorf("expected the mail on its overridden subject: %v", err)
+		}
+	})
+
+	t.Run("Repeated message IDs are dropped by the stream", func(t *testing.T) {
+		route := cfg.Routes.UnlockSeats
+		msg := broker.Message{Route: "unlock_seats", ID: "unlock-1", Body: []byte("[3]")}
+
+		for range 2 {
+			confirmation, err := publisher.Publish(ctx, route, msg)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if acked, err := confirmation.Wait(ctx); !acked || err != nil {
+				t.Fatalf("expected an ack, got %v %v", acked, err)
+			}
+		}
+
+		info, err := js.Stream(ctx, "BOOKING")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := info.Info(ctx, jetstream.WithSubjectFilter("unlock_seats.unlock_seats_key"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.State.Subjects["unlock_seats.unlock_seats_key"] != 1 {
+			t.Errorf("expected one stored message, got %v", got.State.Subjects)
+		}
+	})
+
+	t.Run("Creates and deletes of one Strapi entry are all stored", func(t *testing.T) {
+		uid := "cast-uid-1"
+		cast := producers.ExtendedCastAndCrew{StarpiCastUid: uid}
+
+		cast.Name = "Zendaya"
+
+		if err := producer.Add_Cast_Producer(ctx, cast); err != nil {
+			t.Fatal(err)
+		}
+
+		// An update is another create with the same UID
+		cast.Character = "Chani"
+
+		if err := producer.Add_Cast_Producer(ctx, cast); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := producer.Delete_Cast_Producer(ctx, cast); err != nil {
+			t.Fatal(err)
+		}
+
+		stream, err := js.Stream(ctx, "BOOKING")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := stream.Info(ctx, jetstream.WithSubjectFilter("strapi_create_exchange.>"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := info.State.Subjects; got["strapi_create_exchange.cast_creation"] != 2 || got["strapi_create_exchange.cast_deletion"] != 1 {
+			t.Errorf("expected the create, the update and the delete, got %v", got)
+		}
+	})
+
+	t.Run("Publishes no stream captures are not acked", func(t *testing.T) {
+		next := config.Clone(cfg)
+		next.NATS.Stream = ""
+		next.NATS.Subjects = map[string]string{"lock_seats": "nowhere.lock"}
+		next.NATS.AckWait = 500 * time.Millisecond
+
+		uncaptured, err := broker.NewJetStream(nc, config.NewStore(next))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = producers.NewProducer(uncaptured, config.NewStore(next)).Lock_Seats(ctx, []int{9})
+
+		if err == nil || !errors.Is(err, rpcerrors.ErrBrokerUnavailable) {
+			t.Fatalf("expected ErrBrokerUnavailable, got %v", err)
+		}
+	})
+
+	t.Run("Reconnects are counted", func(t *testing.T) {
+		other, err := broker.ConnectNATS(cfg.NATS)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer other.Close()
+
+		before := testutil.ToFloat64(metrics.Reconnects)
+
+		if err := other.ForceReconnect(); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+
+		for testutil.ToFloat64(metrics.Reconnects) == before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if got := testutil.ToFloat64(metrics.Reconnects); got != before+1 {
+			t.Errorf("expected one reconnect, got %v", got-before)
+		}
+	})
+
+	t.Run("Subject overrides are validated", func(t *testing.T) {
+		path := writeConfig(t, `
+publisher:
+  backend: nats
+nats:
+  subjects:
+    no_such_route: a.b
+    lock_seats: "seats.*"
+`)
+
+		_, _, err := config.Load([]string{"-config", path})
+
+		if err == nil {
+			t.Fatal("expected validation error")
+		}
+
+		for _, want := range []string{"no_such_route", "seats.*"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in %v", want, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/models"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// consume reads n records from topic, failing the test if they do not arrive.
+func consume(t *testing.T, brokers []string, topic string, n int) []*kgo.Record {
+	t.Helper()
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...), kgo.ConsumeTopics(topic), kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var records []*kgo.Record
+
+	for len(records) < n {
+		fetches := client.PollFetches(ctx)
+
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("expected %d records on %s, got %d", n, topic, len(records))
+		}
+
+		records = append(records, fetches.Records()...)
+	}
+
+	return records
+}
+
+func header(r *kgo.Record, key string) string {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
+
+func Test_kafka(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.SeedTopics(8, "payments.succeeded", "strapi_create_exchange"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer cluster.Close()
+
+	cfg := config.Default()
+	cfg.Publisher.Backend = "kafka"
+	cfg.Kafka.Brokers = cluster.ListenAddrs()
+	cfg.Kafka.Topics = map[string]string{"payment_success": "payments.succeeded"}
+	cfg.Kafka.DeliveryTimeout = 2 * time.Second
+
+	store := config.NewStore(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	publisher, err := broker.NewKafka(ctx, store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer publisher.Close(ctx)
+
+	producer := producers.NewProducer(publisher, store)
+
+	t.Run("Payments are keyed by payment id and keep their order", func(t *testing.T) {
+		for _, id := range []string{"pay_1", "pay_2", "pay_1", "pay_1"} {
+			if err := producer.Payment_Service_Producer(ctx, models.Payment{PaymentID: id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		records := consume(t, cfg.Kafka.Brokers, "payments.succeeded", 4)
+
+		var partitions []int32
+
+		for _, r := range records {
+			if string(r.Key) == "pay_1" {
+				partitions = append(partitions, r.Partition)
+			}
+
+			if header(r, "message-id") == "" || header(r, "content-type") != "application/json" {
+				t.Errorf("expected message id and content type headers, got %v", r.Headers)
+			}
+		}
+
+		if len(partitions) != 3 || partitions[0] != partitions[1] || partitions[1] != partitions[2] {
+			t.Errorf("expected pay_1 on a single partition, got %v", partitions)
+		}
+	})
+
+	t.Run("Strapi events are keyed by their uid on the exchange topic", func(t *testing.T) {
+		client := serve(t, store, publisher)
+
+		if _, err := client.Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{Title: "Dune", ReleaseDate: "2024-03-01", Duration: 166, StarpiMovieUid: "movie-uid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Delete_Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{MovieId: 3, StarpiMovieUid: "movie-uid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Deletes do not need the movie id
+		if _, err := client.Cast_Service_Producer(ctx, &rabbitmq_producer.Cast{Name: "Zendaya", MovieId: 3, StarpiCastUidStr: "cast-uid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Delete_Cast_Producer(ctx, &rabbitmq_producer.Cast{CastId: 8, StarpiCastUidStr: "cast-uid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		records := consume(t, cfg.Kafka.Brokers, "strapi_create_exchange", 4)
+		partitions := map[string][]int32{}
+
+		for _, r := range records {
+			if header(r, "correlation-id") != string(r.Key) {
+				t.Errorf("expected the strapi uid as key, got %q with headers %v", r.Key, r.Headers)
+			}
+
+			partitions[string(r.Key)] = append(partitions[string(r.Key)], r.Partition)
+		}
+
+		for _, uid := range []string{"movie-uid", "cast-uid"} {
+			if p := partitions[uid]; len(p) != 2 || p[0] != p[1] {
+				t.Errorf("expected the create and delete of %s on one partition, got %v", uid, p)
+			}
+		}
+	})
+
+	t.Run("Delivery failures map to gRPC codes", func(t *testing.T) {
+		// lock_seats has no topic in the cluster and topics are not auto-created
+		err := producer.Lock_Seats(ctx, []int{1})
+
+		if err == nil {
+			t.Fatal("expected the publish to fail")
+		}
+
+		if code := status.Code(rpcerrors.FromError(err)); code != codes.FailedPrecondition {
+			t.Errorf("expected FailedPrecondition for a missing topic, got %v for %v", code, err)
+		}
+	})
+}
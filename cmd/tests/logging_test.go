@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/logging"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func Test_logging(t *testing.T) {
+	var buf bytes.Buffer
+
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+
+	store := config.NewStore(config.Default())
+	logging.Setup(&buf, store)
+
+	records := func(t *testing.T) []map[string]any {
+		var out []map[string]any
+
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+
+			var record map[string]any
+
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("log line is not JSON: %s", line)
+			}
+
+			out = append(out, record)
+		}
+
+		buf.Reset()
+
+		return out
+	}
+
+	t.Run("Request fields are attached to every record of a call", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-42"))
+		info := &grpc.UnaryServerInfo{FullMethod: rabbitmq_producer.RabbitmqProducerService_Lock_Seats_FullMethodName}
+
+		logging.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			logging.Annotate(ctx, slog.String("caller", "mtls:booking"))
+			slog.InfoContext(ctx, "message published", "exchange", "lock_seats")
+			return nil, nil
+		})
+
+		got := records(t)
+
+		if len(got) != 2 {
+			t.Fatalf("expected 2 records, got %v", got)
+		}
+
+		for _, record := range got {
+			if record["request_id"] != "req-42" || record["method"] != info.FullMethod || record["caller"] != "mtls:booking" {
+				t.Errorf("missing request fields in %v", record)
+			}
+		}
+
+		if got[1]["code"] != "OK" {
+			t.Errorf("expected the outcome in %v", got[1])
+		}
+	})
+
+	t.Run("Personal data is redacted", func(t *testing.T) {
+		var payment models.Payment
+		payment.PaymentID = "pay_1"
+		payment.Customer.Email = "jane@example.com"
+		payment.Customer.Name = "Jane Doe"
+		payment.CardLastFour = "4242"
+
+		slog.Info("sending mail to jane@example.com", "payment", payment, "email", "jane@example.com", "phone_number", "+15550100")
+
+		line := buf.String()
+		records(t)
+
+		for _, leaked := range []string{"jane@example.com", "Jane Doe", "4242", "+15550100"} {
+			if strings.Contains(line, leaked) {
+				t.Errorf("%q leaked into %s", leaked, line)
+			}
+		}
+
+		if !strings.Contains(line, "pay_1") {
+			t.Errorf("expected the payment id in %s", line)
+		}
+	})
+
+	t.Run("Level changes apply on reload", func(t *testing.T) {
+		slog.Debug("hidden")
+
+		next := config.Clone(store.Load())
+		next.Logging.Level = "debug"
+		store.Reload(next)
+
+		slog.Debug("shown")
+
+		got := records(t)
+
+		if len(got) != 1 || got[0]["msg"] != "shown" {
+			t.Fatalf("unexpected records %v", got)
+		}
+	})
+}
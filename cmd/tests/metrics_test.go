@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_metrics(t *testing.T) {
+	interceptor := metrics.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: rabbitmq_producer.RabbitmqProducerService_Lock_Seats_FullMethodName}
+
+	for _, err := range []error{nil, status.Error(codes.ResourceExhausted, "slow down")} {
+		interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			return nil, err
+		})
+	}
+
+	// Other tests publish on lock_seats too
+	attempts := metrics.PublishAttempts.WithLabelValues("lock_seats", "lock_seats_key")
+	attempts.Inc()
+
+	server := httptest.NewServer(metrics.Handler())
+	defer server.Close()
+
+	res, err := server.Client().Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+
+	for _, want := range []string{
+		`producer_rpc_requests_total{code="OK",method="Lock_Seats"} 1`,
+		`producer_rpc_requests_total{code="ResourceExhausted",method="Lock_Seats"} 1`,
+		`producer_rpc_duration_seconds_count{method="Lock_Seats"} 2`,
+		fmt.Sprintf(`producer_publish_attempts_total{exchange="lock_seats",routing_key="lock_seats_key"} %g`, testutil.ToFloat64(attempts)),
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %s in the scrape", want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/audit"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker/brokertest"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/metrics"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/replay"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// gatedPublisher holds every publish until release is closed.
+type gatedPublisher struct {
+	*brokertest.Broker
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *gatedPublisher) Publish(ctx context.Context, route config.Route, msg broker.Message) (broker.Confirmation, error) {
+	p.started <- struct{}{}
+	<-p.release
+
+	return p.Broker.Publish(ctx, route, msg)
+}
+
+func Test_multi(t *testing.T) {
+	cfg := config.Default()
+	cfg.Publisher.Secondaries = []string{"kafka"}
+	store := config.NewStore(cfg)
+
+	setMode := func(mode string) {
+		next := config.Clone(cfg)
+		next.Publisher.Mode = mode
+		store.Reload(next)
+	}
+
+	primary, secondary := brokertest.New(), brokertest.New()
+
+	producer := producers.NewProducer(&broker.Multi{
+		Config:      store,
+		Primary:     broker.Sink{Name: "rabbitmq", Publisher: primary},
+		Secondaries: []broker.Sink{{Name: "kafka", Publisher: secondary}},
+	}, store)
+
+	ctx := context.Background()
+
+	reset := func() {
+		primary.Reset()
+		secondary.Reset()
+	}
+
+	t.Run("Primary-only leaves the secondary alone", func(t *testing.T) {
+		reset()
+		setMode("primary-only")
+
+		if err := producer.Lock_Seats(ctx, []int{1}); err != nil {
+			t.Fatal(err)
+		}
+
+		primary.ExpectMessages(t, "lock_seats_queue", 1)
+
+		if _, declared := secondary.Exchange("lock_seats"); declared || len(secondary.Published()) != 0 {
+			t.Fatal("expected nothing on the secondary")
+		}
+	})
+
+	t.Run("Mirror failures do not fail the publish", func(t *testing.T) {
+		reset()
+		setMode("mirror")
+
+		if err := producer.Lock_Seats(ctx, []int{2}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Mirroring happens in the background
+		deadline := time.Now().Add(5 * time.Second)
+
+		for len(secondary.Messages("lock_seats_queue")) == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		secondary.ExpectMessages(t, "lock_seats_queue", 1)
+
+		failed := testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "lock_seats", "failed"))
+		secondary.FailNext(errors.New("kafka is down"))
+
+		if err := producer.Lock_Seats(ctx, []int{3}); err != nil {
+			t.Fatalf("expected the primary's result, got %v", err)
+		}
+
+		for testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "lock_seats", "failed")) == failed && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if got := testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "lock_seats", "failed")); got != failed+1 {
+			t.Errorf("expected the failed mirror publish to be counted, got %v", got-failed)
+		}
+
+		primary.ExpectMessages(t, "lock_seats_queue", 2)
+	})
+
+	t.Run("All mode needs every backend to accept", func(t *testing.T) {
+		reset()
+		setMode("all")
+
+		if err := producer.Unlock_Seats(ctx, []int{4}); err != nil {
+			t.Fatal(err)
+		}
+
+		primary.ExpectMessages(t, "unlock_seats_queue", 1)
+		secondary.ExpectMessages(t, "unlock_seats_queue", 1)
+
+		secondary.NackNext(1)
+
+		if err := producer.Unlock_Seats(ctx, []int{5}); !errors.Is(err, rpcerrors.ErrNacked) {
+			t.Fatalf("expected ErrNacked when the secondary nacks, got %v", err)
+		}
+
+		secondary.FailNext(errors.New("kafka is down"))
+
+		if err := producer.Unlock_Seats(ctx, []int{6}); err == nil {
+			t.Fatal("expected the secondary's publish error")
+		}
+	})
+
+	t.Run("All mode publishes side by side and names the backends that took the message", func(t *testing.T) {
+		reset()
+		setMode("all")
+
+		gated := &gatedPublisher{Broker: primary, started: make(chan struct{}, 1), release: make(chan struct{})}
+
+		multi := &broker.Multi{
+			Config:      store,
+			Primary:     broker.Sink{Name: "rabbitmq", Publisher: gated},
+			Secondaries: []broker.Sink{{Name: "kafka", Publisher: secondary}},
+		}
+
+		failed := testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "unlock_seats", "failed"))
+		secondary.FailNext(errors.New("kafka is down"))
+
+		done := make(chan error, 1)
+
+		go func() {
+			done <- producers.NewProducer(multi, store).Unlock_Seats(ctx, []int{8})
+		}()
+
+		<-gated.started
+
+		// The secondary is not waiting for the held primary
+		deadline := time.Now().Add(5 * time.Second)
+
+		for testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "unlock_seats", "failed")) == failed && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		close(gated.release)
+
+		if testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "unlock_seats", "failed")) == failed {
+			t.Fatal("expected the secondary to be published to while the primary was busy")
+		}
+
+		err := <-done
+
+		if err == nil || !strings.Contains(err.Error(), "kafka is down") || !strings.Contains(err.Error(), "accepted by rabbitmq") {
+			t.Fatalf("expected the failure and the backend that took the message, got %v", err)
+		}
+
+		primary.ExpectMessages(t, "unlock_seats_queue", 1)
+	})
+
+	t.Run("The audit log records the outcome per backend", func(t *testing.T) {
+		reset()
+		setMode("all")
+
+		dir := t.TempDir()
+		audited := config.Clone(store.Load())
+		audited.Audit.Enabled = true
+		audited.Audit.Directory = dir
+		auditStore := config.NewStore(audited)
+
+		log, err := audit.Open(auditStore, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer log.Close()
+
+		producer := producers.NewProducer(&broker.Multi{
+			Config:      auditStore,
+			Primary:     broker.Sink{Name: "rabbitmq", Publisher: primary},
+			Secondaries: []broker.Sink{{Name: "kafka", Publisher: secondary}},
+		}, auditStore)
+		producer.Audit = log
+
+		if err := producer.Unlock_Seats(ctx, []int{9}); err != nil {
+			t.Fatal(err)
+		}
+
+		secondary.NackNext(1)
+		producer.Unlock_Seats(ctx, []int{10})
+
+		records, err := replay.ReadFiles(dir, replay.Filter{Outcomes: []string{audit.OutcomeConfirmed, audit.OutcomeNacked}})
+
+		if err != nil || len(records) != 2 {
+			t.Fatalf("expected two records, got %d (%v)", len(records), err)
+		}
+
+		if got := records[0].Sinks; got["rabbitmq"] != "confirmed" || got["kafka"] != "confirmed" || records[0].Outcome != audit.OutcomeConfirmed {
+			t.Errorf("expected both backends to confirm, got %s %v", records[0].Outcome, got)
+		}
+
+		if got := records[1].Sinks; got["rabbitmq"] != "confirmed" || got["kafka"] != "nacked" || records[1].Outcome != audit.OutcomeNacked {
+			t.Errorf("expected the secondary's nack, got %s %v", records[1].Outcome, got)
+		}
+	})
+
+	t.Run("Mirror publishes in flight are bounded and drops counted", func(t *testing.T) {
+		reset()
+		setMode("mirror")
+
+		defer func(n int) { broker.MirrorConcurrency = n }(broker.MirrorConcurrency)
+		broker.MirrorConcurrency = 1
+
+		gated := &gatedPublisher{Broker: secondary, started: make(chan struct{}, 1), release: make(chan struct{})}
+		producer := producers.NewProducer(&broker.Multi{
+			Config:      store,
+			Primary:     broker.Sink{Name: "rabbitmq", Publisher: primary},
+			Secondaries: []broker.Sink{{Name: "kafka", Publisher: gated}},
+		}, store)
+
+		dropped := testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "lock_seats", "dropped"))
+
+		if err := producer.Lock_Seats(ctx, []int{11}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-gated.started
+
+		if err := producer.Lock_Seats(ctx, []int{12}); err != nil {
+			t.Fatal(err)
+		}
+
+		close(gated.release)
+
+		if got := testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "lock_seats", "dropped")); got != dropped+1 {
+			t.Errorf("expected one dropped mirror publish, got %v", got-dropped)
+		}
+
+		primary.ExpectMessages(t, "lock_seats_queue", 2)
+	})
+
+	t.Run("Shadow mode only counts what would have been sent", func(t *testing.T) {
+		reset()
+		setMode("shadow")
+
+		shadowed := testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "unlock_seats", "shadowed"))
+
+		if err := producer.Unlock_Seats(ctx, []int{7}); err != nil {
+			t.Fatal(err)
+		}
+
+		primary.ExpectMessages(t, "unlock_seats_queue", 1)
+
+		if len(secondary.Published()) != 0 {
+			t.Fatalf("expected nothing sent to the shadow, got %v", secondary.Published())
+		}
+
+		if got := testutil.ToFloat64(metrics.SinkPublishes.WithLabelValues("kafka", "unlock_seats", "shadowed")); got != shadowed+1 {
+			t.Errorf("expected one shadowed publish, got %v", got-shadowed)
+		}
+	})
+
+	t.Run("Backends are listed once", func(t *testing.T) {
+		path := writeConfig(t, `
+publisher:
+  backend: rabbitmq
+  secondaries: [kafka, rabbitmq]
+  mode: everything
+`)
+
+		_, _, err := config.Load([]string{"-config", path})
+
+		if err == nil {
+			t.Fatal("expected a duplicate backend and an unknown mode to be rejected")
+		}
+
+		for _, want := range []string{"publisher.secondaries[1]", "everything"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in %v", want, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/audit"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker/brokertest"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/ordering"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+)
+
+func Test_ordering(t *testing.T) {
+	t.Run("Keys keep their shard and mostly stay when shards are added", func(t *testing.T) {
+		counts := make([]int, 8)
+		moved := 0
+
+		for i := range 10000 {
+			key := fmt.Sprintf("movie-%d", i)
+			shard := ordering.Shard(key, 8)
+
+			if shard != ordering.Shard(key, 8) || shard < 0 || shard >= 8 {
+				t.Fatalf("unstable or out of range shard %d for %s", shard, key)
+			}
+
+			counts[shard]++
+
+			if ordering.Shard(key, 9) != shard {
+				moved++
+			}
+		}
+
+		for shard, n := range counts {
+			if n < 1000 || n > 1500 {
+				t.Errorf("shard %d got %d of 10000 keys", shard, n)
+			}
+		}
+
+		// Ideally 1/9 of the keys move to the new shard
+		if moved > 1500 {
+			t.Errorf("%d of 10000 keys moved going from 8 to 9 shards", moved)
+		}
+	})
+
+	t.Run("Sequences increase per entity", func(t *testing.T) {
+		var seq ordering.Sequencer
+
+		a1, b1, a2 := seq.Next("a"), seq.Next("b"), seq.Next("a")
+
+		if a2 <= a1 || b1 <= 0 {
+			t.Errorf("expected increasing sequences, got a %d then %d, b %d", a1, a2, b1)
+		}
+	})
+
+	t.Run("Events of one movie share a shard queue in order", func(t *testing.T) {
+		cfg := config.Default()
+
+		for _, r := range []*config.Route{&cfg.Routes.CastCreation, &cfg.Routes.CastDeletion, &cfg.Routes.MovieTimeSlotCreation, &cfg.Routes.MovieCreation, &cfg.Routes.MovieDeletion} {
+			r.Shards = 4
+		}
+
+		client, fake := startService(t, config.NewStore(cfg))
+		ctx := context.Background()
+
+		for i := range 3 {
+			uid := fmt.Sprintf("movie-uid-%d", i)
+
+			if _, err := client.Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{Title: "Dune", ReleaseDate: "2024-03-01", Duration: 166, StarpiMovieUid: uid}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.Delete_Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{MovieId: 3, StarpiMovieUid: uid}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(fake.Messages("strapi_create")) != 0 {
+			t.Fatal("expected nothing on the unsharded queue")
+		}
+
+		fake.ExpectNoReturns(t)
+
+		total := 0
+
+		for shard := range 4 {
+			queue := fmt.Sprintf("strapi_create.%d", shard)
+			last := map[string]int64{}
+
+			for _, msg := range fake.Messages(queue) {
+				key, _ := msg.Headers[ordering.KeyHeader].(string)
+				seq, _ := msg.Headers[ordering.SequenceHeader].(int64)
+
+				if ordering.Shard(key, 4) != shard || msg.Headers[ordering.ShardHeader] != shard {
+					t.Errorf("%s is on %s", key, queue)
+				}
+
+				if seq <= last[key] {
+					t.Errorf("%s: sequence %d after %d", key, seq, last[key])
+				}
+
+				last[key] = seq
+				total++
+			}
+		}
+
+		if total != 6 {
+			t.Errorf("expected 6 events over the shards, got %d", total)
+		}
+	})
+
+	t.Run("Replays keep their shard and sequence", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Routes.MovieCreation.Shards = 4
+		cfg.Routes.MovieDeletion.Shards = 4
+		cfg.Routes.CastCreation.Shards = 4
+		cfg.Routes.CastDeletion.Shards = 4
+		cfg.Routes.MovieTimeSlotCreation.Shards = 4
+
+		fake := brokertest.New()
+		producer := producers.NewProducer(fake, config.NewStore(cfg))
+		ctx := context.Background()
+
+		if err := producer.Movie_Producer(ctx, producers.MoviePayload{StarpiMovieUid: "movie-uid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		queue := fmt.Sprintf("strapi_create.%d", ordering.Shard("movie-uid", 4))
+		original := fake.Messages(queue)[0]
+		seq, _ := original.Headers[ordering.SequenceHeader].(int64)
+
+		if err := producer.Movie_Producer(ctx, producers.MoviePayload{StarpiMovieUid: "movie-uid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		record := audit.Record{Route: "movie_creation", MessageID: original.ID, CorrelationID: "movie-uid", Key: "movie-uid", Sequence: seq, Payload: original.Body}
+
+		if err := producer.Replay(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+
+		messages := fake.Messages(queue)
+
+		if len(messages) != 3 || messages[2].Headers[ordering.SequenceHeader] != seq {
+			t.Fatalf("expected the replay to keep sequence %d on %s, got %v", seq, queue, messages[len(messages)-1].Headers)
+		}
+
+		if newer, _ := messages[1].Headers[ordering.SequenceHeader].(int64); newer <= seq {
+			t.Errorf("expected the newer event to stay ahead of the replay, got %d after %d", newer, seq)
+		}
+	})
+
+	t.Run("Routes to one queue need the same shards", func(t *testing.T) {
+		path := writeConfig(t, `
+routes:
+  movie_creation:
+    shards: 4
+`)
+
+		_, _, err := config.Load([]string{"-config", path})
+
+		if err == nil || !strings.Contains(err.Error(), "strapi_create") {
+			t.Fatalf("expected mismatched shards on strapi_create to be rejected, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+)
+
+type stubConfirmation bool
+
+func (c stubConfirmation) Wait(ctx context.Context) (bool, error) {
+	return bool(c), nil
+}
+
+// stubPublisher stands in for a broker, recording declarations and messages.
+type stubPublisher struct {
+	declared []string
+	sent     []broker.Message
+	confirm  broker.Confirmation
+	err      error
+}
+
+func (p *stubPublisher) Declare(ctx context.Context, route config.Route) error {
+	p.declared = append(p.declared, route.Exchange)
+	return nil
+}
+
+func (p *stubPublisher) Publish(ctx context.Context, route config.Route, msg broker.Message) (broker.Confirmation, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	p.sent = append(p.sent, msg)
+
+	return p.confirm, nil
+}
+
+func Test_producer(t *testing.T) {
+	store := config.NewStore(config.Default())
+
+	t.Run("Events go through the publisher with an ID and content type", func(t *testing.T) {
+		publisher := &stubPublisher{confirm: stubConfirmation(true)}
+		producer := producers.NewProducer(publisher, store)
+
+		if err := producer.Lock_Seats(context.Background(), []int{1, 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(publisher.declared) != 1 || publisher.declared[0] != "lock_seats" {
+			t.Fatalf("expected the lock_seats exchange to be declared, got %v", publisher.declared)
+		}
+
+		msg := publisher.sent[0]
+
+		if msg.ID == "" || msg.ContentType != "application/json" || string(msg.Body) != "[1,2]" {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	})
+
+	t.Run("Nacks and publish errors are reported", func(t *testing.T) {
+		producer := producers.NewProducer(&stubPublisher{confirm: stubConfirmation(false)}, store)
+
+		if err := producer.Unlock_Seats(context.Background(), []int{1}); !errors.Is(err, rpcerrors.ErrNacked) {
+			t.Fatalf("expected ErrNacked, got %v", err)
+		}
+
+		producer = producers.NewProducer(&stubPublisher{err: errors.New("channel closed")}, store)
+
+		if err := producer.Unlock_Seats(context.Background(), []int{1}); err == nil {
+			t.Fatal("expected the publish error")
+		}
+	})
+
+	t.Run("Publishes without confirms succeed once sent", func(t *testing.T) {
+		publisher := &stubPublisher{}
+
+		if err := producers.NewProducer(publisher, store).Lock_Seats(context.Background(), []int{3}); err != nil || len(publisher.sent) != 1 {
+			t.Fatalf("expected one message, got %v %v", err, publisher.sent)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/auth"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/ratelimit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_rate_limits(t *testing.T) {
+	cms := auth.Identity{Name: "cms-bridge", Method: "apikey"}
+	booking := auth.Identity{Name: "booking", Method: "mtls"}
+
+	newLimiter := func(t *testing.T, limits config.LimitsConfig) (*ratelimit.Limiter, *config.Store) {
+		cfg := config.Default()
+		cfg.Limits = limits
+
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		store := config.NewStore(cfg)
+
+		return ratelimit.NewLimiter(store), store
+	}
+
+	t.Run("Method limits are shared by all callers", func(t *testing.T) {
+		limiter, _ := newLimiter(t, config.LimitsConfig{
+			Methods: map[string]string{"Movie_Producer": "1/h:2"},
+		})
+
+		if err := limiter.Allow("Movie_Producer", cms); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Allow("Movie_Producer", booking); err != nil {
+			t.Fatal(err)
+		}
+
+		err := limiter.Allow("Movie_Producer", cms)
+
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("expected ResourceExhausted, got %v", err)
+		}
+
+		var retry *errdetails.RetryInfo
+
+		for _, d := range status.Convert(err).Details() {
+			if r, ok := d.(*errdetails.RetryInfo); ok {
+				retry = r
+			}
+		}
+
+		if retry == nil || retry.RetryDelay.AsDuration() <= 0 {
+			t.Fatalf("expected a retry delay, got %v", status.Convert(err).Details())
+		}
+
+		if err := limiter.Allow("Lock_Seats", cms); err != nil {
+			t.Fatalf("other methods must not be limited: %v", err)
+		}
+	})
+
+	t.Run("Caller limits keep a runaway caller from starving others", func(t *testing.T) {
+		limiter, _ := newLimiter(t, config.LimitsConfig{
+			Callers: map[string]string{"*": "1/h", "booking": "100/s"},
+		})
+
+		if err := limiter.Allow("Movie_Producer", cms); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Allow("Cast_Service_Producer", cms); status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("expected the cms bridge to be limited, got %v", err)
+		}
+
+		for i := 0; i < 10; i++ {
+			if err := limiter.Allow("Lock_Seats", booking); err != nil {
+				t.Fatalf("booking call %d was limited: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("Reloaded limits apply to existing buckets", func(t *testing.T) {
+		limiter, store := newLimiter(t, config.LimitsConfig{
+			Methods: map[string]string{"Lock_Seats": "1/h"},
+		})
+
+		if err := limiter.Allow("Lock_Seats", booking); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := limiter.Allow("Lock_Seats", booking); err == nil {
+			t.Fatal("expected the second call to be limited")
+		}
+
+		next := config.Clone(store.Load())
+		next.Limits.Methods = nil
+		store.Reload(next)
+
+		if err := limiter.Allow("Lock_Seats", booking); err != nil {
+			t.Fatalf("expected no limit after reload, got %v", err)
+		}
+	})
+
+	t.Run("Invalid limits are rejected", func(t *testing.T) {
+		for _, spec := range []string{"10", "0/s", "5/d", "5/s:0"} {
+			if _, err := config.ParseLimit(spec); err == nil {
+				t.Errorf("expected %q to be rejected", spec)
+			}
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/audit"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker/brokertest"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/database"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/replay"
+)
+
+type recordingPublisher struct {
+	published []string
+	fail      string
+}
+
+func (p *recordingPublisher) Replay(ctx context.Context, r audit.Record) error {
+	if r.MessageID == p.fail {
+		return errors.New("publish failed")
+	}
+
+	p.published = append(p.published, r.MessageID)
+
+	return nil
+}
+
+func messageIDs(records []audit.Record) []string {
+	var ids []string
+
+	for _, r := range records {
+		ids = append(ids, r.MessageID)
+	}
+
+	return ids
+}
+
+func Test_replay(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg := config.Default()
+	cfg.Audit.Enabled = true
+	cfg.Audit.Directory = dir
+	cfg.Audit.Database = true
+	cfg.Database = config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(dir, "producer.db")}
+
+	db, err := database.Open(cfg.Database)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := audit.Open(config.NewStore(cfg), db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer log.Close()
+
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	events := []struct {
+		at      time.Duration
+		id      string
+		route   string
+		body    string
+		replay  bool
+		outcome string
+	}{
+		{1 * time.Hour, "cast-1", "cast_creation", `{"action":"create","model":"cast","data":{"strapi_cast_uid":"uid-7"}}`, false, audit.OutcomeConfirmed},
+		{2 * time.Hour, "movie-1", "movie_creation", `{"action":"create","model":"movie","data":{"strapi_movie_uid":"uid-9"}}`, false, audit.OutcomeConfirmed},
+		{3 * time.Hour, "cast-1", "cast_creation", `{"action":"create","model":"cast","data":{"strapi_cast_uid":"uid-7"}}`, true, audit.OutcomeConfirmed},
+		{3*time.Hour + 30*time.Minute, "cast-1", "cast_deletion", `{"action":"delete","model":"cast","data":{"strapi_cast_uid":"uid-7"}}`, false, audit.OutcomeConfirmed},
+		{3*time.Hour + 50*time.Minute, "pay-1", "payment_success", `{"payment_id":"pay_1","customer":{"email":"jane@example.com"}}`, false, audit.OutcomeFailed},
+		{4 * time.Hour, "pay-1", "payment_success", `{"payment_id":"pay_1","customer":{"email":"jane@example.com"}}`, false, audit.OutcomeConfirmed},
+		{5 * time.Hour, "movie-3", "movie_creation", `{"action":"create","model":"movie","data":{"strapi_movie_uid":"uid-9"}}`, false, audit.OutcomeRejected},
+		{30 * time.Hour, "movie-2", "movie_deletion", `{"action":"delete","model":"movie","data":{"strapi_movie_uid":"uid-9"}}`, false, audit.OutcomeConfirmed},
+	}
+
+	for _, e := range events {
+		log.Record(context.Background(), audit.Record{
+			PublishedAt: day.Add(e.at),
+			MessageID:   e.id,
+			Route:       e.route,
+			Replay:      e.replay,
+			Outcome:     e.outcome,
+		}, []byte(e.body))
+	}
+
+	t.Run("Filters select events and leave out earlier replays", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			filter replay.Filter
+			want   []string
+		}{
+			{"everything", replay.Filter{}, []string{"cast-1", "movie-1", "cast-1", "pay-1", "movie-2"}},
+			{"route", replay.Filter{Routes: []string{"cast_creation", "movie_creation"}}, []string{"cast-1", "movie-1"}},
+			{"time range", replay.Filter{From: day, To: day.Add(24 * time.Hour)}, []string{"cast-1", "movie-1", "cast-1", "pay-1"}},
+			{"entity", replay.Filter{EntityID: "uid-9"}, []string{"movie-1", "movie-2"}},
+			{"message id", replay.Filter{MessageIDs: []string{"pay-1"}}, []string{"pay-1"}},
+		}
+
+		for _, c := range cases {
+			for source, read := range map[string]func(replay.Filter) ([]audit.Record, error){
+				"files":    func(f replay.Filter) ([]audit.Record, error) { return replay.ReadFiles(dir, f) },
+				"database": func(f replay.Filter) ([]audit.Record, error) { return replay.ReadDatabase(db, f) },
+			} {
+				records, err := read(c.filter)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := messageIDs(records); !slices.Equal(got, c.want) {
+					t.Errorf("%s from %s: expected %v, got %v", c.name, source, c.want, got)
+				}
+			}
+		}
+	})
+
+	t.Run("A create and a delete sharing a message ID are both replayed", func(t *testing.T) {
+		filter := replay.Filter{Routes: []string{"cast_creation", "cast_deletion"}}
+
+		for source, read := range map[string]func(replay.Filter) ([]audit.Record, error){
+			"files":    func(f replay.Filter) ([]audit.Record, error) { return replay.ReadFiles(dir, f) },
+			"database": func(f replay.Filter) ([]audit.Record, error) { return replay.ReadDatabase(db, f) },
+		} {
+			records, err := read(filter)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var routes []string
+
+			for _, r := range records {
+				routes = append(routes, r.Route)
+			}
+
+			if !slices.Equal(routes, []string{"cast_creation", "cast_deletion"}) {
+				t.Errorf("from %s: expected the create then the delete, got %v", source, routes)
+			}
+		}
+	})
+
+	t.Run("Only delivered events are replayed unless asked for", func(t *testing.T) {
+		for source, read := range map[string]func(replay.Filter) ([]audit.Record, error){
+			"files":    func(f replay.Filter) ([]audit.Record, error) { return replay.ReadFiles(dir, f) },
+			"database": func(f replay.Filter) ([]audit.Record, error) { return replay.ReadDatabase(db, f) },
+		} {
+			if records, err := read(replay.Filter{MessageIDs: []string{"pay-1", "movie-3"}}); err != nil || len(records) != 1 || records[0].Outcome != audit.OutcomeConfirmed {
+				t.Errorf("from %s: expected only the delivered retry of pay-1, got %+v (%v)", source, records, err)
+			}
+
+			records, err := read(replay.Filter{Outcomes: []string{audit.OutcomeRejected}})
+
+			if got := messageIDs(records); err != nil || !slices.Equal(got, []string{"movie-3"}) {
+				t.Errorf("from %s: expected the rejected movie-3 when asked for, got %v (%v)", source, got, err)
+			}
+		}
+	})
+
+	t.Run("Redacted events are skipped and failures counted", func(t *testing.T) {
+		records, err := replay.ReadFiles(dir, replay.Filter{})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		publisher := &recordingPublisher{fail: "movie-2"}
+
+		result, err := replay.Run(context.Background(), publisher, records, replay.Options{}, func(audit.Record, error) {})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result != (replay.Result{Published: 3, Skipped: 1, Failed: 1}) {
+			t.Errorf("unexpected result %+v", result)
+		}
+
+		if !slices.Equal(publisher.published, []string{"cast-1", "movie-1", "cast-1"}) {
+			t.Errorf("unexpected publishes %v", publisher.published)
+		}
+	})
+
+	t.Run("Dry runs publish nothing", func(t *testing.T) {
+		records, _ := replay.ReadFiles(dir, replay.Filter{Routes: []string{"movie_creation"}})
+		publisher := &recordingPublisher{}
+
+		var listed []string
+
+		result, err := replay.Run(context.Background(), publisher, records, replay.Options{DryRun: true}, func(r audit.Record, _ error) {
+			listed = append(listed, r.MessageID)
+		})
+
+		if err != nil || result.Published != 1 || len(publisher.published) != 0 || !slices.Equal(listed, []string{"movie-1"}) {
+			t.Errorf("unexpected dry run: %+v %v %v %v", result, err, publisher.published, listed)
+		}
+	})
+
+	t.Run("The rate limit paces re-publishes", func(t *testing.T) {
+		records, _ := replay.ReadFiles(dir, replay.Filter{Routes: []string{"cast_creation", "movie_creation"}})
+		records = append(records, records...)
+
+		start := time.Now()
+
+		if _, err := replay.Run(context.Background(), &recordingPublisher{}, records, replay.Options{Rate: 20}, func(audit.Record, error) {}); err != nil {
+			t.Fatal(err)
+		}
+
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("expected 4 events at 20/s to be paced, took %s", elapsed)
+		}
+	})
+
+	t.Run("Replayed events are recorded as replays and not picked up again", func(t *testing.T) {
+		records, _ := replay.ReadFiles(dir, replay.Filter{MessageIDs: []string{"movie-1"}})
+
+		producer := producers.NewProducer(brokertest.New(), config.NewStore(cfg))
+		producer.Audit = log
+
+		if err := producer.Replay(context.Background(), records[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		for source, read := range map[string]func(replay.Filter) ([]audit.Record, error){
+			"files":    func(f replay.Filter) ([]audit.Record, error) { return replay.ReadFiles(dir, f) },
+			"database": func(f replay.Filter) ([]audit.Record, error) { return replay.ReadDatabase(db, f) },
+		} {
+			if again, err := read(replay.Filter{MessageIDs: []string{"movie-1"}}); err != nil || len(again) != 1 {
+				t.Errorf("from %s: expected only the original event, got %d (%v)", source, len(again), err)
+			}
+		}
+
+		var replays int64
+
+		db.Model(&audit.Record{}).Where("message_id = ? AND replay = ?", "movie-1", true).Count(&replays)
+
+		if replays != 1 {
+			t.Errorf("expected the replay to be recorded as one, got %d", replays)
+		}
+	})
+}
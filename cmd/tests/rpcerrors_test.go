@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_rpcerrors(t *testing.T) {
+
+	cases := []struct {
+		name  string
+		err   error
+		code  codes.Code
+		retry bool
+	}{
+		{"broker closed", fmt.Errorf("publish failed: %w", amqp091.ErrClosed), codes.Unavailable, true},
+		{"broker unavailable", rpcerrors.ErrBrokerUnavailable, codes.Unavailable, true},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded, true},
+		{"cancelled", context.Canceled, codes.Canceled, false},
+		{"backpressure", rpcerrors.ErrBackpressure, codes.ResourceExhausted, true},
+		{"missing exchange", &amqp091.Error{Code: amqp091.NotFound, Reason: "no exchange"}, codes.FailedPrecondition, false},
+		{"kafka record too large", fmt.Errorf("delivery failed: %w", kerr.MessageTooLarge), codes.InvalidArgument, false},
+		{"kafka topic not authorized", kerr.TopicAuthorizationFailed, codes.PermissionDenied, false},
+		{"kafka not enough replicas", kerr.NotEnoughReplicas, codes.Unavailable, true},
+		{"unknown", errors.New("boom"), codes.Internal, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			st := status.Convert(rpcerrors.FromError(c.err))
+
+			if st.Code() != c.code {
+				t.Fatalf("expected %s, got %s", c.code, st.Code())
+			}
+
+			hasRetry := false
+
+			for _, d := range st.Details() {
+				if _, ok := d.(*errdetails.RetryInfo); ok {
+					hasRetry = true
+				}
+			}
+
+			if hasRetry != c.retry {
+				t.Fatalf("expected retry info %v, got %v", c.retry, hasRetry)
+			}
+		})
+	}
+
+	t.Run("Existing status errors are passed through", func(t *testing.T) {
+		in := status.Error(codes.InvalidArgument, "bad seat")
+
+		if got := rpcerrors.FromError(in); got != in {
+			t.Fatalf("expected the same error back, got %v", got)
+		}
+
+		if rpcerrors.Message(in) != "bad seat" {
+			t.Fatalf("unexpected legacy message %q", rpcerrors.Message(in))
+		}
+	})
+}
@@ -0,0 +1,355 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker/brokertest"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/database"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/saga"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// startSaga serves the producer service with booking sagas over an in-memory
+// broker whose mail events land on the mails queue.
+func startSaga(t *testing.T, cfg *config.Config) (rabbitmq_producer.RabbitmqProducerServiceClient, *brokertest.Broker, *saga.Orchestrator) {
+	t.Helper()
+
+	cfg.Saga.Enabled = true
+	cfg.Database = config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "producer.db")}
+	store := config.NewStore(cfg)
+
+	db, err := database.Open(cfg.Database)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := brokertest.New()
+	fake.Bind("send_mail", "mails", "send_mail_key")
+
+	producer := producers.NewProducer(fake, store)
+	orchestrator, err := saga.Open(store, db, producer)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := serveService(t, &producers.Rabbitmq_Producer_Service{Producer: *producer, Saga: orchestrator, Config: store})
+
+	return client, fake, orchestrator
+}
+
+func bookingContext(id string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), saga.BookingIDHeader, id, saga.EmailHeader, "jane@example.com", saga.NameHeader, "Jane")
+}
+
+// bookingPayment is a valid payment request for paymentID.
+func bookingPayment(paymentID string, metadata map[string]string) *rabbitmq_producer.Payment_Service_Producer_Request {
+	return &rabbitmq_producer.Payment_Service_Producer_Request{PaymentPayload: &rabbitmq_producer.Payment{
+		PaymentId:   paymentID,
+		Currency:    "INR",
+		TotalAmount: 1200,
+		Billing:     &rabbitmq_producer.Payment_Billing{City: "Pune"},
+		Customer:    &rabbitmq_producer.Payment_Customer{Email: "jane@example.com", Name: "Jane"},
+		CreatedAt:   timestamppb.Now(),
+		Metadata:    metadata,
+	}}
+}
+
+func expectState(t *testing.T, o *saga.Orchestrator, id, state string) saga.Booking {
+	t.Helper()
+
+	b, err := o.Get(context.Background(), id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.State != state {
+		t.Fatalf("expected booking %s to be %s, got %s (%s)", id, state, b.State, b.LastError)
+	}
+
+	return b
+}
+
+// flakyMail fails the first mail it is asked to send.
+type flakyMail struct {
+	saga.Publisher
+	failed bool
+	mails  int
+}
+
+func (f *flakyMail) Send_Mail_Producer(ctx context.Context, mail *rabbitmq_producer.Send_Mail_Producer_Request) error {
+	if !f.failed {
+		f.failed = true
+		return rpcerrors.ErrBrokerUnavailable
+	}
+
+	f.mails++
+
+	return f.Publisher.Send_Mail_Producer(ctx, mail)
+}
+
+func Test_saga(t *testing.T) {
+	t.Run("A paid booking is confirmed and mailed once", func(t *testing.T) {
+		client, fake, o := startSaga(t, config.Default())
+		ctx := bookingContext("b-1")
+
+		if _, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{4, 5}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if locks := fake.Messages("lock_seats_queue"); len(locks) != 1 || string(locks[0].Body) != "[4,5]" {
+			t.Fatalf("expected the seats to be locked, got %v", locks)
+		}
+
+		expectState(t, o, "b-1", saga.AwaitingPayment)
+
+		// The payment names the booking in its metadata rather than the headers
+		payment := bookingPayment("pay_1", map[string]string{"booking_id": "b-1"})
+
+		for range 2 {
+			if _, err := client.Payment_Service_Webhook_Producer(context.Background(), payment); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		b := expectState(t, o, "b-1", saga.Confirmed)
+
+		if b.PaymentID != "pay_1" {
+			t.Errorf("expected the payment to be recorded, got %q", b.PaymentID)
+		}
+
+		if n := len(fake.Messages("payment_service_success")); n != 2 {
+			t.Errorf("expected both payment events to go out, got %d", n)
+		}
+
+		mails := fake.Messages("mails")
+
+		if len(mails) != 1 || !strings.Contains(string(mails[0].Body), "booking_confirmation") || !strings.Contains(string(mails[0].Body), "jane@example.com") {
+			t.Fatalf("expected one confirmation mail, got %v", mails)
+		}
+
+		if len(fake.Messages("unlock_seats_queue")) != 0 {
+			t.Error("expected the seats to stay locked")
+		}
+	})
+
+	t.Run("A failed payment unlocks the seats and mails the customer", func(t *testing.T) {
+		client, fake, o := startSaga(t, config.Default())
+		ctx := bookingContext("b-2")
+
+		if _, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{7}}); err != nil {
+			t.Fatal(err)
+		}
+
+		payment := bookingPayment("pay_2", nil)
+		payment.PaymentPayload.ErrorMessage = "card declined"
+
+		if _, err := client.Payment_Service_Failure_Producer(ctx, payment); err != nil {
+			t.Fatal(err)
+		}
+
+		b := expectState(t, o, "b-2", saga.Cancelled)
+
+		if b.Reason != "card declined" {
+			t.Errorf("expected the decline as reason, got %q", b.Reason)
+		}
+
+		if unlocks := fake.Messages("unlock_seats_queue"); len(unlocks) != 1 || string(unlocks[0].Body) != "[7]" {
+			t.Errorf("expected the seats to be unlocked, got %v", unlocks)
+		}
+
+		if mails := fake.Messages("mails"); len(mails) != 1 || !strings.Contains(string(mails[0].Body), "booking_failed") {
+			t.Errorf("expected one failure mail, got %v", mails)
+		}
+	})
+
+	t.Run("Unpaid bookings time out and late payments leave them cancelled", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Saga.PaymentTimeout = time.Millisecond
+
+		client, fake, o := startSaga(t, cfg)
+		ctx := context.Background()
+
+		if err := o.Start(ctx, "b-3", []int{1}, saga.Customer{Email: "jane@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+
+		if err := o.Advance(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if b := expectState(t, o, "b-3", saga.Cancelled); b.Reason != "payment timed out" {
+			t.Errorf("expected a timeout as reason, got %q", b.Reason)
+		}
+
+		if _, err := client.Payment_Service_Webhook_Producer(bookingContext("b-3"), bookingPayment("pay_3", nil)); err != nil {
+			t.Fatal(err)
+		}
+
+		expectState(t, o, "b-3", saga.Cancelled)
+
+		if n := len(fake.Messages("mails")); n != 1 {
+			t.Errorf("expected only the failure mail, got %d mails", n)
+		}
+	})
+
+	t.Run("Failed steps are retried", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Saga.RetryInterval = time.Millisecond
+
+		_, fake, o := startSaga(t, cfg)
+		mail := &flakyMail{Publisher: o.Publisher}
+		o.Publisher = mail
+
+		ctx := context.Background()
+
+		if err := o.Start(ctx, "b-4", []int{2}, saga.Customer{Email: "jane@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := o.PaymentSucceeded(ctx, "b-4", "pay_4", saga.Customer{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if b := expectState(t, o, "b-4", saga.Confirming); b.Attempts != 1 || b.LastError == "" {
+			t.Errorf("expected the failed mail to be recorded, got %+v", b)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+
+		if err := o.Advance(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		expectState(t, o, "b-4", saga.Confirmed)
+
+		if mail.mails != 1 || len(fake.Messages("mails")) != 1 {
+			t.Errorf("expected the mail to go out on the retry, sent %d", mail.mails)
+		}
+	})
+
+	t.Run("Locks are idempotent per booking", func(t *testing.T) {
+		client, fake, o := startSaga(t, config.Default())
+		ctx := bookingContext("b-5")
+
+		fake.FailNext(rpcerrors.ErrBackpressure)
+
+		if _, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{3}}); err == nil {
+			t.Fatal("expected the failed lock to be reported")
+		}
+
+		if _, err := o.Get(context.Background(), "b-5"); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected the booking to be forgotten after a failed lock, got %v", err)
+		}
+
+		for range 2 {
+			if _, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{3}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{9}})
+
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected other seats for the booking to be refused, got %v", err)
+		}
+
+		expectState(t, o, "b-5", saga.AwaitingPayment)
+	})
+
+	t.Run("Seats of a lock that may have been sent are released", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Saga.RetryInterval = time.Millisecond
+
+		client, fake, o := startSaga(t, cfg)
+
+		fake.FailNext(errors.New("channel closed"))
+
+		if _, err := client.Lock_Seats(bookingContext("b-6"), &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{8}}); err == nil {
+			t.Fatal("expected the failed lock to be reported")
+		}
+
+		expectState(t, o, "b-6", saga.ReleasingSeats)
+
+		time.Sleep(5 * time.Millisecond)
+
+		if err := o.Advance(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if b := expectState(t, o, "b-6", saga.Cancelled); b.Reason != "seat lock failed" {
+			t.Errorf("expected the failed lock as reason, got %q", b.Reason)
+		}
+
+		if unlocks := fake.Messages("unlock_seats_queue"); len(unlocks) != 1 || string(unlocks[0].Body) != "[8]" {
+			t.Errorf("expected the seats to be unlocked, got %v", unlocks)
+		}
+	})
+
+	t.Run("Payments are not published when their booking cannot be moved", func(t *testing.T) {
+		client, fake, o := startSaga(t, config.Default())
+		ctx := bookingContext("b-7")
+
+		if _, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{1}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := o.DB.Migrator().RenameTable("booking_sagas", "booking_sagas_away"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Payment_Service_Webhook_Producer(ctx, bookingPayment("pay_7", nil)); err == nil {
+			t.Fatal("expected the payment to fail with the booking")
+		}
+
+		if n := len(fake.Messages("payment_service_success")); n != 0 {
+			t.Fatalf("expected nothing published, got %d payments", n)
+		}
+
+		if err := o.DB.Migrator().RenameTable("booking_sagas_away", "booking_sagas"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The caller retries, and retries again after a lost response
+		for range 2 {
+			if _, err := client.Payment_Service_Webhook_Producer(ctx, bookingPayment("pay_7", nil)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expectState(t, o, "b-7", saga.Confirmed)
+
+		if n := len(fake.Messages("mails")); n != 1 {
+			t.Errorf("expected one confirmation mail, got %d", n)
+		}
+	})
+
+	t.Run("Sagas need a database", func(t *testing.T) {
+		path := writeConfig(t, `
+saga:
+  enabled: true
+`)
+
+		_, _, err := config.Load([]string{"-config", path})
+
+		if err == nil || !strings.Contains(err.Error(), "saga.enabled") {
+			t.Fatalf("expected sagas without a database to be rejected, got %v", err)
+		}
+	})
+}
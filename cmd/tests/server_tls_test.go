@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/auth"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/tlsutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_server_tls(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := ca.write(t, dir)
+	certPath, keyPath := ca.issue(t, dir, "server", "producer-v1", "")
+	clientCert, clientKey := ca.issue(t, dir, "client", "gateway", "spiffe://booking/payment-gateway")
+
+	reloader, err := tlsutil.NewReloader(config.ServerTLSConfig{
+		CertFile:     certPath,
+		KeyFile:      keyPath,
+		ClientCAFile: caPath,
+		ClientAuth:   "require",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(chan auth.Identity, 1)
+
+	server := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(reloader.TLSConfig())),
+		grpc.ChainUnaryInterceptor(
+			auth.UnaryIdentityInterceptor(),
+			func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				seen <- auth.FromContext(ctx)
+				return &rabbitmq_producer.Lock_Seats_Response{}, nil
+			},
+		),
+	)
+
+	rabbitmq_producer.RegisterRabbitmqProducerServiceServer(server, &producers.Rabbitmq_Producer_Service{})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go server.Serve(lis)
+	defer server.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+
+	dial := func(t *testing.T, withClientCert bool) (string, error) {
+		pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serverName := make(chan string, 1)
+
+		clientTLS := &tls.Config{
+			RootCAs:    pool,
+			ServerName: "localhost",
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				serverName <- cs.PeerCertificates[0].Subject.CommonName
+				return nil
+			},
+		}
+
+		if withClientCert {
+			clientTLS.Certificates = []tls.Certificate{pair}
+		}
+
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		_, err = rabbitmq_producer.NewRabbitmqProducerServiceClient(conn).Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{1}})
+
+		select {
+		case name := <-serverName:
+			return name, err
+		default:
+			return "", err
+		}
+	}
+
+	t.Run("Client certificate identity reaches the handlers", func(t *testing.T) {
+		if _, err := dial(t, true); err != nil {
+			t.Fatal(err)
+		}
+
+		id := <-seen
+
+		if id.String() != "mtls:spiffe://booking/payment-gateway" {
+			t.Fatalf("unexpected identity %s", id)
+		}
+	})
+
+	t.Run("Clients without a certificate are refused", func(t *testing.T) {
+		if _, err := dial(t, false); err == nil {
+			t.Fatal("expected the handshake to fail without a client certificate")
+		}
+	})
+
+	t.Run("Rotated certificates are served after reload", func(t *testing.T) {
+		ca.issue(t, dir, "server", "producer-v2", "")
+
+		if err := reloader.Reload(); err != nil {
+			t.Fatal(err)
+		}
+
+		name, err := dial(t, true)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		<-seen
+
+		if name != "producer-v2" {
+			t.Fatalf("expected the rotated certificate, got %s", name)
+		}
+	})
+}
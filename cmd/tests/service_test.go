@@ -0,0 +1,398 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker/brokertest"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/logging"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/rpcerrors"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// startService serves the producer service over bufconn, publishing to an
+// in-memory broker.
+func startService(t *testing.T, store *config.Store) (rabbitmq_producer.RabbitmqProducerServiceClient, *brokertest.Broker) {
+	t.Helper()
+
+	fake := brokertest.New()
+
+	return serve(t, store, fake), fake
+}
+
+// serve serves the producer service over bufconn, publishing to publisher.
+func serve(t *testing.T, store *config.Store, publisher broker.Publisher) rabbitmq_producer.RabbitmqProducerServiceClient {
+	t.Helper()
+
+	return serveService(t, &producers.Rabbitmq_Producer_Service{
+		Producer: producers.Producer{Publisher: publisher, Config: store},
+		Config:   store,
+	})
+}
+
+// serveService serves service over bufconn.
+func serveService(t *testing.T, service *producers.Rabbitmq_Producer_Service) rabbitmq_producer.RabbitmqProducerServiceClient {
+	t.Helper()
+
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		logging.UnaryServerInterceptor(),
+		validation.UnaryServerInterceptor(),
+	))
+
+	rabbitmq_producer.RegisterRabbitmqProducerServiceServer(server, service)
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return rabbitmq_producer.NewRabbitmqProducerServiceClient(conn)
+}
+
+type strapiMessage struct {
+	Action string         `json:"action"`
+	Model  string         `json:"model"`
+	Data   map[string]any `json:"data"`
+}
+
+func Test_service(t *testing.T) {
+	store := config.NewStore(config.Default())
+	client, fake := startService(t, store)
+
+	// Nothing in this service binds a queue for mail; the mail consumer does
+	fake.Bind("send_mail", "send_mail_queue", "send_mail_key")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payment := &rabbitmq_producer.Payment{
+		PaymentId:   "pay_1",
+		Currency:    "INR",
+		TotalAmount: 1200,
+		Billing:     &rabbitmq_producer.Payment_Billing{City: "Pune"},
+		Customer:    &rabbitmq_producer.Payment_Customer{Email: "jane@example.com", Name: "Jane"},
+		CreatedAt:   timestamppb.Now(),
+	}
+
+	t.Run("Payment webhooks and failures go to their exchanges", func(t *testing.T) {
+		fake.Reset()
+
+		if _, err := client.Payment_Service_Webhook_Producer(ctx, &rabbitmq_producer.Payment_Service_Producer_Request{PaymentPayload: payment}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Payment_Service_Failure_Producer(ctx, &rabbitmq_producer.Payment_Service_Producer_Request{PaymentPayload: payment}); err != nil {
+			t.Fatal(err)
+		}
+
+		published := fake.Published()
+
+		if len(published) != 2 {
+			t.Fatalf("expected 2 publishes, got %d", len(published))
+		}
+
+		for i, exchange := range []string{"payment_success_exchange", "payment_failure_exchange"} {
+			var got map[string]any
+			brokertest.Decode(t, published[i].Message, &got)
+
+			if published[i].Exchange != exchange || got["payment_id"] != "pay_1" {
+				t.Errorf("expected pay_1 on %s, got %v on %s", exchange, got, published[i].Exchange)
+			}
+		}
+
+		// Mandatory publishes with the default topology must reach the payment queues
+		fake.ExpectMessages(t, "payment_service_success", 1)
+		fake.ExpectMessages(t, "payment_service_failure", 1)
+		fake.ExpectNoReturns(t)
+	})
+
+	t.Run("Seat locks and unlocks carry the seat ids", func(t *testing.T) {
+		fake.Reset()
+
+		if _, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{4, 5}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Unlock_Seats(ctx, &rabbitmq_producer.Unlock_Seats_Request{SeatIds: []int32{6}}); err != nil {
+			t.Fatal(err)
+		}
+
+		var locked, unlocked []int
+		brokertest.Decode(t, fake.ExpectMessages(t, "lock_seats_queue", 1)[0], &locked)
+		brokertest.Decode(t, fake.ExpectMessages(t, "unlock_seats_queue", 1)[0], &unlocked)
+
+		if len(locked) != 2 || locked[1] != 5 || len(unlocked) != 1 || unlocked[0] != 6 {
+			t.Errorf("unexpected seats %v %v", locked, unlocked)
+		}
+	})
+
+	t.Run("Mail requests are published to the mail exchange", func(t *testing.T) {
+		fake.Reset()
+
+		_, err := client.Send_Mail_Producer(ctx, &rabbitmq_producer.Send_Mail_Producer_Request{
+			To:      "jane@example.com",
+			Subject: "Your tickets",
+			Text:    "Enjoy the movie",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var mail map[string]any
+		brokertest.Decode(t, fake.ExpectMessages(t, "send_mail_queue", 1)[0], &mail)
+
+		if mail["subject"] != "Your tickets" {
+			t.Errorf("unexpected mail %v", mail)
+		}
+
+		fake.ExpectNoReturns(t)
+	})
+
+	t.Run("Strapi events share the strapi_create queue", func(t *testing.T) {
+		fake.Reset()
+
+		calls := []func() error{
+			func() error {
+				_, err := client.Cast_Service_Producer(ctx, &rabbitmq_producer.Cast{Name: "Zendaya", MovieId: 3, StarpiCastUidStr: "cast-uid"})
+				return err
+			},
+			func() error {
+				_, err := client.Delete_Cast_Producer(ctx, &rabbitmq_producer.Cast{CastId: 8, StarpiCastUidStr: "cast-uid"})
+				return err
+			},
+			func() error {
+				_, err := client.Movie_Time_Slot_Producer(ctx, &rabbitmq_producer.Movie_Time_Slot_Strapi{
+					Starttime:              "2026-03-01T18:00:00Z",
+					Endtime:                "2026-03-01T21:00:00Z",
+					Date:                   "2026-03-01",
+					Duration:               180,
+					MovieId:                3,
+					VenueId:                2,
+					Format:                 rabbitmq_producer.MovieFormat_IMAX,
+					StarpiMovieTimeslotUid: "slot-uid",
+				})
+				return err
+			},
+			func() error {
+				_, err := client.Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{Title: "Dune", ReleaseDate: "2024-03-01", Duration: 166, StarpiMovieUid: "movie-uid"})
+				return err
+			},
+			func() error {
+				_, err := client.Delete_Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{MovieId: 3, StarpiMovieUid: "movie-uid"})
+				return err
+			},
+		}
+
+		for _, call := range calls {
+			if err := call(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		msgs := fake.ExpectMessages(t, "strapi_create", len(calls))
+
+		want := []struct{ action, model, id string }{
+			{"create", "cast-and-crew", "cast-uid"},
+			{"delete", "cast-and-crew", "cast-uid"},
+			{"create", "movie-time-slot", "slot-uid"},
+			{"create", "movie", "movie-uid"},
+			{"delete", "movie", "movie-uid"},
+		}
+
+		for i, w := range want {
+			var got strapiMessage
+			brokertest.Decode(t, msgs[i], &got)
+
+			if got.Action != w.action || got.Model != w.model || msgs[i].ID != w.id {
+				t.Errorf("message %d: expected %s %s %s, got %s %s %s", i, w.action, w.model, w.id, got.Action, got.Model, msgs[i].ID)
+			}
+		}
+
+		for i, d := range fake.Published() {
+			if d.RoutingKey != []string{"cast_creation", "cast_deletion", "movie_time_slot_creation", "movie_creation", "movie_deletion"}[i] {
+				t.Errorf("publish %d used routing key %s", i, d.RoutingKey)
+			}
+		}
+	})
+
+	t.Run("Venue sync is not implemented", func(t *testing.T) {
+		_, err := client.Venue_Producer(ctx, &rabbitmq_producer.Venue_Strapi{Venueid: 1})
+
+		if status.Code(err) != codes.Unimplemented {
+			t.Fatalf("expected Unimplemented, got %v", err)
+		}
+	})
+
+	t.Run("Broker failures map to gRPC codes", func(t *testing.T) {
+		fake.Reset()
+		fake.NackNext(1)
+
+		_, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{1}})
+
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable for a nack, got %v", err)
+		}
+
+		fake.ExpectMessages(t, "lock_seats_queue", 0)
+
+		next := config.Clone(store.Load())
+		next.Routes.LockSeats.Enabled = false
+		store.Reload(next)
+		defer store.Reload(config.Default())
+
+		_, err = client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{1}})
+
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected FailedPrecondition for a disabled route, got %v", err)
+		}
+	})
+
+	// gRPC drops the response of a failed call, whatever the handler returned
+	t.Run("Failed calls reach the client only as a status with details", func(t *testing.T) {
+		fake.Reset()
+		fake.NackNext(1)
+
+		resp, err := client.Cast_Service_Producer(ctx, &rabbitmq_producer.Cast{Name: "Zendaya", MovieId: 3, StarpiCastUidStr: "cast-uid"})
+
+		if resp != nil {
+			t.Fatalf("expected no response on failure, got %v", resp)
+		}
+
+		st := status.Convert(err)
+
+		if st.Code() != codes.Unavailable || st.Message() == "" {
+			t.Fatalf("expected Unavailable with a message, got %v", err)
+		}
+
+		var retry *errdetails.RetryInfo
+
+		for _, d := range st.Details() {
+			if r, ok := d.(*errdetails.RetryInfo); ok {
+				retry = r
+			}
+		}
+
+		if retry == nil || retry.GetRetryDelay().AsDuration() != rpcerrors.RetryDelay {
+			t.Fatalf("expected a retry delay of %s, got %v", rpcerrors.RetryDelay, st.Details())
+		}
+
+		movie, err := client.Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{Title: "Dune", ReleaseDate: "soon", StarpiMovieUid: "movie-uid"})
+
+		if movie != nil || status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected only InvalidArgument, got %v and %v", movie, err)
+		}
+	})
+
+	t.Run("Responses carry the status message in their error field", func(t *testing.T) {
+		service := &producers.Rabbitmq_Producer_Service{
+			Producer: producers.Producer{Publisher: fake, Config: store},
+			Config:   store,
+		}
+
+		fake.Reset()
+
+		ok, err := service.Unlock_Seats(ctx, &rabbitmq_producer.Unlock_Seats_Request{SeatIds: []int32{1}})
+
+		if err != nil || ok == nil || ok.Error != "" {
+			t.Fatalf("expected an empty error field on success, got %v and %v", ok, err)
+		}
+
+		fake.NackNext(1)
+
+		failed, err := service.Unlock_Seats(ctx, &rabbitmq_producer.Unlock_Seats_Request{SeatIds: []int32{1}})
+
+		if failed == nil || failed.Error == "" || failed.Error != status.Convert(err).Message() {
+			t.Fatalf("expected the status message %q in the error field, got %v", status.Convert(err).Message(), failed)
+		}
+
+		movie, err := service.Movie_Producer(ctx, &rabbitmq_producer.Movie_Strapi{Title: "Dune", ReleaseDate: "soon"})
+
+		if movie == nil || movie.Error != status.Convert(err).Message() {
+			t.Fatalf("expected the validation message in the error field, got %v", movie)
+		}
+	})
+
+	t.Run("Invalid requests never reach the broker", func(t *testing.T) {
+		fake.Reset()
+
+		_, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{})
+
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, got %v", err)
+		}
+
+		if len(fake.Published()) != 0 {
+			t.Fatalf("expected nothing published, got %v", fake.Published())
+		}
+	})
+}
+
+func Test_brokertest(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Topic and fanout exchanges route like RabbitMQ", func(t *testing.T) {
+		fake := brokertest.New()
+
+		fake.DeclareExchange("events", "topic", true)
+		fake.DeclareExchange("broadcast", "fanout", true)
+		fake.Bind("events", "all", "booking.#")
+		fake.Bind("events", "created", "booking.*.created")
+		fake.Bind("broadcast", "a", "")
+		fake.Bind("broadcast", "b", "ignored")
+
+		for _, key := range []string{"booking.seat.created", "booking.seat.deleted", "booking", "payment.created"} {
+			fake.Publish(ctx, config.Route{Exchange: "events", RoutingKey: key}, brokertest.Message(key))
+		}
+
+		fake.Publish(ctx, config.Route{Exchange: "broadcast", RoutingKey: "x"}, brokertest.Message("hello"))
+
+		fake.ExpectMessages(t, "all", 3)
+		fake.ExpectMessages(t, "created", 1)
+		fake.ExpectMessages(t, "a", 1)
+		fake.ExpectMessages(t, "b", 1)
+
+		if r := fake.Returns(); len(r) != 1 || r[0].RoutingKey != "payment.created" {
+			t.Fatalf("expected payment.created to be returned, got %v", r)
+		}
+	})
+
+	t.Run("Redeclaring an exchange with another type fails", func(t *testing.T) {
+		fake := brokertest.New()
+
+		if err := fake.DeclareExchange("events", "topic", true); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fake.DeclareExchange("events", "direct", true); err == nil {
+			t.Fatal("expected a precondition failure")
+		}
+
+		if _, err := fake.Publish(ctx, config.Route{Exchange: "missing"}, brokertest.Message("x")); err == nil {
+			t.Fatal("expected publishing to an undeclared exchange to fail")
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stallingPublisher holds every declare until the publish's context ends, like
+// a broker that stopped answering.
+type stallingPublisher struct {
+	declaring chan struct{}
+	gaveUp    chan error
+	published atomic.Int32
+}
+
+func newStallingPublisher() *stallingPublisher {
+	return &stallingPublisher{declaring: make(chan struct{}, 1), gaveUp: make(chan error, 1)}
+}
+
+func (p *stallingPublisher) Declare(ctx context.Context, route config.Route) error {
+	p.declaring <- struct{}{}
+	<-ctx.Done()
+	p.gaveUp <- ctx.Err()
+
+	return ctx.Err()
+}
+
+func (p *stallingPublisher) Publish(ctx context.Context, route config.Route, msg broker.Message) (broker.Confirmation, error) {
+	p.published.Add(1)
+	return nil, nil
+}
+
+func Test_timeouts(t *testing.T) {
+	t.Run("A caller that cancels mid-publish gets Canceled and nothing is published", func(t *testing.T) {
+		publisher := newStallingPublisher()
+		client := serve(t, config.NewStore(config.Default()), publisher)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		go func() {
+			<-publisher.declaring
+			cancel()
+		}()
+
+		_, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{1}})
+
+		if status.Code(err) != codes.Canceled {
+			t.Fatalf("expected Canceled, got %v", err)
+		}
+
+		select {
+		case err := <-publisher.gaveUp:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected the handler to see the cancellation, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the handler kept publishing after the caller cancelled")
+		}
+
+		if n := publisher.published.Load(); n != 0 {
+			t.Errorf("expected nothing published, got %d", n)
+		}
+	})
+
+	t.Run("The method timeout applies under a longer caller deadline", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Server.Timeouts = map[string]time.Duration{"Lock_Seats": 50 * time.Millisecond}
+
+		publisher := newStallingPublisher()
+		client := serve(t, config.NewStore(cfg), publisher)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		start := time.Now()
+
+		_, err := client.Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{1}})
+
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("expected DeadlineExceeded, got %v", err)
+		}
+
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the 50ms cap to apply, took %s", elapsed)
+		}
+
+		if ctx.Err() != nil {
+			t.Error("expected the caller's own deadline not to have passed")
+		}
+
+		<-publisher.gaveUp
+
+		if n := publisher.published.Load(); n != 0 {
+			t.Errorf("expected nothing published, got %d", n)
+		}
+	})
+}
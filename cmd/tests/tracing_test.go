@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker/brokertest"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/tracing"
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func Test_tracing(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), config.TracingConfig{Exporter: "none"}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	t.Run("Trace context flows from the caller into AMQP headers", func(t *testing.T) {
+		headers := make(chan amqp091.Table, 1)
+
+		server := grpc.NewServer(
+			grpc.StatsHandler(otelgrpc.NewServerHandler()),
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				headers <- tracing.Inject(ctx, nil)
+				return &rabbitmq_producer.Lock_Seats_Response{}, nil
+			}),
+		)
+
+		rabbitmq_producer.RegisterRabbitmqProducerServiceServer(server, &producers.Rabbitmq_Producer_Service{})
+
+		lis := bufconn.Listen(1 << 20)
+		go server.Serve(lis)
+		defer server.Stop()
+
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer conn.Close()
+
+		ctx, span := provider.Tracer("gateway").Start(context.Background(), "book seats")
+		_, err = rabbitmq_producer.NewRabbitmqProducerServiceClient(conn).Lock_Seats(ctx, &rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{1}})
+		span.End()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := <-headers
+		consumer := trace.SpanContextFromContext(tracing.Extract(context.Background(), got))
+
+		if consumer.TraceID() != span.SpanContext().TraceID() {
+			t.Fatalf("expected trace %s in headers %v", span.SpanContext().TraceID(), got)
+		}
+
+		var serverSpan bool
+
+		for _, s := range recorder.Ended() {
+			if s.SpanKind() == trace.SpanKindServer && strings.HasSuffix(s.Name(), "/Lock_Seats") {
+				serverSpan = s.Parent().TraceID() == span.SpanContext().TraceID()
+			}
+		}
+
+		if !serverSpan {
+			t.Fatal("expected a Lock_Seats server span in the caller's trace")
+		}
+	})
+
+	t.Run("Publish spans name the configured backend", func(t *testing.T) {
+		for _, backend := range []string{"rabbitmq", "kafka", "nats", "file"} {
+			cfg := config.Default()
+			cfg.Publisher.Backend = backend
+
+			producer := producers.NewProducer(brokertest.New(), config.NewStore(cfg))
+
+			if err := producer.Lock_Seats(context.Background(), []int{1}); err != nil {
+				t.Fatal(err)
+			}
+
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+
+			var system string
+
+			for _, attr := range span.Attributes() {
+				if attr.Key == semconv.MessagingSystemKey {
+					system = attr.Value.AsString()
+				}
+			}
+
+			want := backend
+
+			if backend == "file" {
+				want = ""
+			}
+
+			if span.SpanKind() != trace.SpanKindProducer || system != want {
+				t.Errorf("expected %s to be traced as %q, got %q on %s", backend, want, system, span.Name())
+			}
+		}
+	})
+
+	t.Run("The stdout exporter can write to a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "spans.json")
+
+		shutdown, err := tracing.Setup(context.Background(), config.TracingConfig{
+			Exporter:    "stdout",
+			File:        path,
+			SampleRatio: 1,
+			ServiceName: "producer-test",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer otel.SetTracerProvider(provider)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "publish lock_seats")
+		span.End()
+
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(data), "publish lock_seats") || !strings.Contains(string(data), "producer-test") {
+			t.Fatalf("span missing from %s", data)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"testing"
+
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func fieldViolations(t *testing.T, err error) map[string]string {
+	t.Helper()
+
+	st, ok := status.FromError(err)
+
+	if !ok || st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument status, got %v", err)
+	}
+
+	fields := map[string]string{}
+
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, fv := range br.GetFieldViolations() {
+				fields[fv.GetField()] = fv.GetDescription()
+			}
+		}
+	}
+
+	return fields
+}
+
+func Test_validation(t *testing.T) {
+
+	t.Run("Sparse payment request is rejected instead of panicking", func(t *testing.T) {
+		err := validation.Validate(
+			rabbitmq_producer.RabbitmqProducerService_Payment_Service_Webhook_Producer_FullMethodName,
+			&rabbitmq_producer.Payment_Service_Producer_Request{
+				PaymentPayload: &rabbitmq_producer.Payment{PaymentId: "pay_1", Currency: "XYZ"},
+			},
+		)
+
+		fields := fieldViolations(t, err)
+
+		for _, f := range []string{"payment_payload.billing", "payment_payload.customer", "payment_payload.created_at", "payment_payload.currency"} {
+			if _, ok := fields[f]; !ok {
+				t.Errorf("expected violation for %s, got %v", f, fields)
+			}
+		}
+	})
+
+	t.Run("Complete payment request passes", func(t *testing.T) {
+		err := validation.Validate(
+			rabbitmq_producer.RabbitmqProducerService_Payment_Service_Failure_Producer_FullMethodName,
+			&rabbitmq_producer.Payment_Service_Producer_Request{
+				PaymentPayload: &rabbitmq_producer.Payment{
+					PaymentId: "pay_1",
+					Currency:  "inr",
+					Billing:   &rabbitmq_producer.Payment_Billing{City: "Pune"},
+					Customer:  &rabbitmq_producer.Payment_Customer{Email: "jane@example.com"},
+					CreatedAt: timestamppb.Now(),
+				},
+			},
+		)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("Seat ids must be positive", func(t *testing.T) {
+		err := validation.Validate(
+			rabbitmq_producer.RabbitmqProducerService_Lock_Seats_FullMethodName,
+			&rabbitmq_producer.Lock_Seats_Request{SeatIds: []int32{4, 0, -2}},
+		)
+
+		fields := fieldViolations(t, err)
+
+		if len(fields) != 2 {
+			t.Fatalf("expected 2 violations, got %v", fields)
+		}
+	})
+
+	t.Run("Mail recipient must be an email address", func(t *testing.T) {
+		err := validation.Validate(
+			rabbitmq_producer.RabbitmqProducerService_Send_Mail_Producer_FullMethodName,
+			&rabbitmq_producer.Send_Mail_Producer_Request{To: "not-an-email", Subject: "Tickets", Text: "hi"},
+		)
+
+		if _, ok := fieldViolations(t, err)["to"]; !ok {
+			t.Fatalf("expected violation for to")
+		}
+	})
+
+	t.Run("Time slot end must be after start", func(t *testing.T) {
+		err := validation.Validate(
+			rabbitmq_producer.RabbitmqProducerService_Movie_Time_Slot_Producer_FullMethodName,
+			&rabbitmq_producer.Movie_Time_Slot_Strapi{
+				Starttime:              "2025-01-01T18:00:00Z",
+				Endtime:                "2025-01-01T16:00:00Z",
+				Date:                   "2025-01-01",
+				Duration:               120,
+				MovieId:                1,
+				VenueId:                1,
+				StarpiMovieTimeslotUid: "slot_1",
+			},
+		)
+
+		fields := fieldViolations(t, err)
+
+		if _, ok := fields["endtime"]; !ok || len(fields) != 1 {
+			t.Fatalf("expected only an endtime violation, got %v", fields)
+		}
+	})
+}
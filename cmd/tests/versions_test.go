@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/audit"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/broker/brokertest"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/database"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/models"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/producers"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/versions"
+)
+
+func openVersions(t *testing.T, path string) *versions.Store {
+	t.Helper()
+
+	db, err := database.Open(config.DatabaseConfig{Driver: "sqlite", DSN: path})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := versions.Open(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func Test_versions(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "producer.db")
+	store := openVersions(t, path)
+
+	t.Run("Versions increase per entity and survive a restart", func(t *testing.T) {
+		for want := int64(1); want <= 3; want++ {
+			if v, err := store.Next(ctx, "movie:a"); err != nil || v != want {
+				t.Fatalf("expected version %d, got %d (%v)", want, v, err)
+			}
+		}
+
+		if v, err := store.Next(ctx, "movie:b"); err != nil || v != 1 {
+			t.Fatalf("expected another entity to start at 1, got %d (%v)", v, err)
+		}
+
+		if v, err := openVersions(t, path).Next(ctx, "movie:a"); err != nil || v != 4 {
+			t.Fatalf("expected version 4 after reopening, got %d (%v)", v, err)
+		}
+	})
+
+	t.Run("Concurrent publishers never share a version", func(t *testing.T) {
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			seen = map[int64]bool{}
+		)
+
+		for range 20 {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				v, err := store.Next(ctx, "payment:concurrent")
+
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				if seen[v] {
+					t.Errorf("version %d handed out twice", v)
+				}
+
+				seen[v] = true
+			}()
+		}
+
+		wg.Wait()
+
+		if v, _ := store.Current(ctx, "payment:concurrent"); v != 20 {
+			t.Errorf("expected version 20, got %d", v)
+		}
+	})
+
+	t.Run("Payment events carry their payment's version and replays keep theirs", func(t *testing.T) {
+		fake := brokertest.New()
+		producer := producers.NewProducer(fake, config.NewStore(config.Default()))
+		producer.Versions = store
+
+		if err := producer.Payment_Service_Producer(ctx, models.Payment{PaymentID: "pay_1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := producer.Payment_Service_Failure_Producer(ctx, models.Payment{PaymentID: "pay_1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := producer.Lock_Seats(ctx, []int{1}); err != nil {
+			t.Fatal(err)
+		}
+
+		success := fake.Messages("payment_service_success")
+		failure := fake.Messages("payment_service_failure")
+
+		if len(success) != 1 || len(failure) != 1 {
+			t.Fatalf("expected one event per queue, got %d and %d", len(success), len(failure))
+		}
+
+		if success[0].Headers[versions.Header] != int64(1) || failure[0].Headers[versions.Header] != int64(2) {
+			t.Errorf("expected versions 1 and 2, got %v and %v", success[0].Headers[versions.Header], failure[0].Headers[versions.Header])
+		}
+
+		if _, ok := fake.Messages("lock_seats_queue")[0].Headers[versions.Header]; ok {
+			t.Error("expected seat locks to be unversioned")
+		}
+
+		record := audit.Record{Route: "payment_success", MessageID: success[0].ID, Key: "pay_1", Version: 1, Payload: success[0].Body}
+
+		if err := producer.Replay(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+
+		replayed := fake.Messages("payment_service_success")
+
+		if len(replayed) != 2 || replayed[1].Headers[versions.Header] != int64(1) {
+			t.Errorf("expected the replay to keep version 1, got %v", replayed[len(replayed)-1].Headers)
+		}
+
+		if v, _ := store.Current(ctx, "payment:pay_1"); v != 2 {
+			t.Errorf("expected the replay not to take a version, current is %d", v)
+		}
+	})
+
+	t.Run("Versions need a database", func(t *testing.T) {
+		path := writeConfig(t, `
+versions:
+  enabled: true
+`)
+
+		_, _, err := config.Load([]string{"-config", path})
+
+		if err == nil || !strings.Contains(err.Error(), "versions.enabled") {
+			t.Fatalf("expected versions without a database to be rejected, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+)
+
+// Reloader serves the gRPC listener's certificate and client CA pool and swaps
+// them in place when the files change, so certificates can be rotated without
+// dropping existing connections or restarting the process.
+type Reloader struct {
+	cfg config.ServerTLSConfig
+
+	cert atomic.Pointer[tls.Certificate]
+	pool atomic.Pointer[x509.CertPool]
+}
+
+func NewReloader(cfg config.ServerTLSConfig) (*Reloader, error) {
+	r := &Reloader{cfg: cfg}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload reads the certificate, key and client CA bundle again. On error the
+// previously loaded material stays in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+
+	if err != nil {
+		return fmt.Errorf("loading server certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+
+	if r.cfg.ClientCAFile != "" {
+		pool, err = config.LoadCertPool(r.cfg.ClientCAFile)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	r.cert.Store(&cert)
+	r.pool.Store(pool)
+
+	return nil
+}
+
+// TLSConfig returns a server configuration that always uses the latest material.
+func (r *Reloader) TLSConfig() *tls.Config {
+	clientAuth := tls.NoClientCert
+
+	switch r.cfg.ClientAuth {
+	case "optional":
+		clientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert.Load()},
+				ClientCAs:    r.pool.Load(),
+				ClientAuth:   clientAuth,
+				NextProtos:   []string{"h2"},
+			}, nil
+		},
+	}
+}
+
+// Watch reloads the material whenever one of the files changes until ctx is cancelled.
+func (r *Reloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return err
+	}
+
+	defer watcher.Close()
+
+	files := map[string]bool{}
+
+	for _, f := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+
+		files[filepath.Clean(f)] = true
+
+		if err := watcher.Add(filepath.Dir(f)); err != nil {
+			return fmt.Errorf("watching %s: %w", f, err)
+		}
+	}
+
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-watcher.Events:
+			if files[filepath.Clean(ev.Name)] || filepath.Base(ev.Name) == "..data" {
+				debounce = time.After(250 * time.Millisecond)
+			}
+		case <-debounce:
+			debounce = nil
+
+			if err := r.Reload(); err != nil {
+				slog.Error("keeping current server certificate, reload failed", "error", err)
+				continue
+			}
+
+			slog.Info("server certificate reloaded")
+		case err := <-watcher.Errors:
+			slog.Warn("certificate watch error", "error", err)
+		}
+	}
+}
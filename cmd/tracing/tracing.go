@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kartik7120/booking_rabbitmq_producer_service/cmd/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+)
+
+// Setup installs the global tracer provider and W3C trace context propagation
+// for the configured exporter. The returned function flushes pending spans.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+
+	switch cfg.Exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		exp, err := otlptracegrpc.New(ctx, opts...)
+
+		if err != nil {
+			return nil, fmt.Errorf("creating otlp exporter: %w", err)
+		}
+
+		exporter = exp
+	case "stdout":
+		var out io.Writer = os.Stdout
+
+		if cfg.File != "" {
+			f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+
+			if err != nil {
+				return nil, fmt.Errorf("opening trace file: %w", err)
+			}
+
+			out, closer = f, f
+		}
+
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(out))
+
+		if err != nil {
+			return nil, err
+		}
+
+		exporter = exp
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+		resource.WithFromEnv(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+
+		if closer != nil {
+			closer.Close()
+		}
+
+		return err
+	}, nil
+}
+
+// HeaderCarrier adapts message headers, such as an AMQP table, for trace context propagation.
+type HeaderCarrier map[string]any
+
+func (c HeaderCarrier) Get(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// Inject writes the trace context of ctx into headers, creating them if needed,
+// so consumers can continue the trace.
+func Inject(ctx context.Context, headers map[string]any) map[string]any {
+	if headers == nil {
+		headers = map[string]any{}
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(headers))
+
+	return headers
+}
+
+// Extract returns ctx with the trace context carried by headers.
+func Extract(ctx context.Context, headers map[string]any) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(headers))
+}
@@ -0,0 +1,117 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	rabbitmq_producer "github.com/kartik7120/booking_rabbitmq_producer_service/cmd/grpcServer"
+)
+
+// rules maps every validated RPC to the checks its request has to pass.
+var rules = map[string]func(req any, v *Violations){
+	rabbitmq_producer.RabbitmqProducerService_Payment_Service_Webhook_Producer_FullMethodName: func(req any, v *Violations) {
+		paymentRequest(req.(*rabbitmq_producer.Payment_Service_Producer_Request), v)
+	},
+	rabbitmq_producer.RabbitmqProducerService_Payment_Service_Failure_Producer_FullMethodName: func(req any, v *Violations) {
+		paymentRequest(req.(*rabbitmq_producer.Payment_Service_Producer_Request), v)
+	},
+	rabbitmq_producer.RabbitmqProducerService_Lock_Seats_FullMethodName: func(req any, v *Violations) {
+		seatIds(req.(*rabbitmq_producer.Lock_Seats_Request).GetSeatIds(), v)
+	},
+	rabbitmq_producer.RabbitmqProducerService_Unlock_Seats_FullMethodName: func(req any, v *Violations) {
+		seatIds(req.(*rabbitmq_producer.Unlock_Seats_Request).GetSeatIds(), v)
+	},
+	rabbitmq_producer.RabbitmqProducerService_Send_Mail_Producer_FullMethodName: func(req any, v *Violations) {
+		in := req.(*rabbitmq_producer.Send_Mail_Producer_Request)
+
+		v.Email("to", in.GetTo())
+		v.NotEmpty("subject", in.GetSubject())
+
+		if in.GetText() == "" && in.GetHtml() == "" {
+			v.Add("text", "text or html is required")
+		}
+	},
+	rabbitmq_producer.RabbitmqProducerService_Cast_Service_Producer_FullMethodName: func(req any, v *Violations) {
+		in := req.(*rabbitmq_producer.Cast)
+
+		v.NotEmpty("name", in.GetName())
+		v.NotEmpty("starpi_cast_uid_str", in.GetStarpiCastUidStr())
+		v.Positive("movie_id", int64(in.GetMovieId()))
+	},
+	rabbitmq_producer.RabbitmqProducerService_Delete_Cast_Producer_FullMethodName: func(req any, v *Violations) {
+		in := req.(*rabbitmq_producer.Cast)
+
+		v.NotEmpty("starpi_cast_uid_str", in.GetStarpiCastUidStr())
+		v.Positive("cast_id", int64(in.GetCastId()))
+	},
+	rabbitmq_producer.RabbitmqProducerService_Movie_Time_Slot_Producer_FullMethodName: func(req any, v *Violations) {
+		in := req.(*rabbitmq_producer.Movie_Time_Slot_Strapi)
+
+		start, startOk := v.Time("starttime", time.RFC3339, in.GetStarttime())
+		end, endOk := v.Time("endtime", time.RFC3339, in.GetEndtime())
+
+		if startOk && endOk {
+			v.After("endtime", start, end)
+		}
+
+		v.Time("date", "2006-01-02", in.GetDate())
+		v.Positive("duration", int64(in.GetDuration()))
+		v.Positive("movie_id", int64(in.GetMovieId()))
+		v.Positive("venue_id", int64(in.GetVenueId()))
+		v.NotEmpty("starpi_movie_timeslot_uid", in.GetStarpiMovieTimeslotUid())
+	},
+	rabbitmq_producer.RabbitmqProducerService_Movie_Producer_FullMethodName: func(req any, v *Violations) {
+		in := req.(*rabbitmq_producer.Movie_Strapi)
+
+		v.NotEmpty("title", in.GetTitle())
+		v.NotEmpty("starpi_movie_uid", in.GetStarpiMovieUid())
+		v.Time("release_date", "2006-01-02", in.GetReleaseDate())
+		v.Positive("duration", int64(in.GetDuration()))
+	},
+	rabbitmq_producer.RabbitmqProducerService_Delete_Movie_Producer_FullMethodName: func(req any, v *Violations) {
+		in := req.(*rabbitmq_producer.Movie_Strapi)
+
+		v.NotEmpty("starpi_movie_uid", in.GetStarpiMovieUid())
+		v.Positive("movie_id", int64(in.GetMovieId()))
+	},
+}
+
+func paymentRequest(in *rabbitmq_producer.Payment_Service_Producer_Request, v *Violations) {
+	payment := in.GetPaymentPayload()
+
+	if !v.Required("payment_payload", payment != nil) {
+		return
+	}
+
+	v.NotEmpty("payment_payload.payment_id", payment.GetPaymentId())
+	v.Required("payment_payload.billing", payment.GetBilling() != nil)
+	v.Required("payment_payload.created_at", payment.GetCreatedAt() != nil)
+
+	if v.NotEmpty("payment_payload.currency", payment.GetCurrency()) {
+		v.Currency("payment_payload.currency", payment.GetCurrency())
+	}
+
+	v.Currency("payment_payload.settlement_currency", payment.GetSettlementCurrency())
+
+	if v.Required("payment_payload.customer", payment.GetCustomer() != nil) {
+		v.Email("payment_payload.customer.email", payment.GetCustomer().GetEmail())
+	}
+
+	for i, d := range payment.GetDisputes() {
+		v.Currency(fmt.Sprintf("payment_payload.disputes[%d].currency", i), d.GetCurrency())
+	}
+
+	for i, rf := range payment.GetRefunds() {
+		v.Currency(fmt.Sprintf("payment_payload.refunds[%d].currency", i), rf.GetCurrency())
+	}
+}
+
+func seatIds(ids []int32, v *Violations) {
+	if !v.Required("seatIds", len(ids) > 0) {
+		return
+	}
+
+	for i, id := range ids {
+		v.Positive(fmt.Sprintf("seatIds[%d]", i), int64(id))
+	}
+}
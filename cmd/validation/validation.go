@@ -0,0 +1,139 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Violations collects the field level problems found in a single request.
+type Violations struct {
+	fields []*errdetails.BadRequest_FieldViolation
+}
+
+func (v *Violations) Add(field, description string) {
+	v.fields = append(v.fields, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	})
+}
+
+func (v *Violations) Fields() []*errdetails.BadRequest_FieldViolation {
+	return v.fields
+}
+
+// Required reports the field as missing when present is false and returns present,
+// so callers can skip the checks that depend on the nested message.
+func (v *Violations) Required(field string, present bool) bool {
+	if !present {
+		v.Add(field, "is required")
+	}
+	return present
+}
+
+func (v *Violations) NotEmpty(field, value string) bool {
+	return v.Required(field, strings.TrimSpace(value) != "")
+}
+
+func (v *Violations) Email(field, value string) {
+	if !v.NotEmpty(field, value) {
+		return
+	}
+
+	addr, err := mail.ParseAddress(value)
+
+	if err != nil || addr.Address != value {
+		v.Add(field, "must be a valid email address")
+	}
+}
+
+func (v *Violations) Positive(field string, value int64) {
+	if value <= 0 {
+		v.Add(field, "must be greater than zero")
+	}
+}
+
+// Currency accepts ISO 4217 alphabetic codes. Empty values are left to Required.
+func (v *Violations) Currency(field, value string) {
+	if value == "" {
+		return
+	}
+
+	if _, ok := currencies[strings.ToUpper(value)]; !ok {
+		v.Add(field, fmt.Sprintf("%q is not an ISO 4217 currency code", value))
+	}
+}
+
+// Time parses value with layout and records a violation when it is missing or malformed.
+func (v *Violations) Time(field, layout, value string) (time.Time, bool) {
+	if !v.NotEmpty(field, value) {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(layout, value)
+
+	if err != nil {
+		v.Add(field, fmt.Sprintf("must be formatted as %s", layout))
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+func (v *Violations) After(field string, start, end time.Time) {
+	if !end.After(start) {
+		v.Add(field, "must be after the start time")
+	}
+}
+
+// Err returns nil when no violations were recorded, otherwise an InvalidArgument
+// status carrying a BadRequest detail with every violation.
+func (v *Violations) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("request has %d invalid field(s)", len(v.fields)))
+
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: v.fields})
+
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// Validate runs the rules registered for the given full gRPC method name.
+// Methods without rules are accepted as is.
+func Validate(method string, req any) error {
+	rule, ok := rules[method]
+
+	if !ok {
+		return nil
+	}
+
+	var v Violations
+
+	rule(req, &v)
+
+	return v.Err()
+}
+
+// UnaryServerInterceptor rejects requests that fail validation before they reach a handler.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := Validate(info.FullMethod, req); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,71 @@
+// Package versions hands out a version per entity that increases with every
+// event about it, kept in the database so it survives restarts.
+package versions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Header carries the entity's version on each event.
+const Header = "x-entity-version"
+
+// Version is the last version handed out for an entity.
+type Version struct {
+	Entity    string `gorm:"primaryKey"`
+	Version   int64  `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func (Version) TableName() string {
+	return "entity_versions"
+}
+
+type Store struct {
+	DB *gorm.DB
+}
+
+// Open migrates the entity_versions table.
+func Open(db *gorm.DB) (*Store, error) {
+	if err := db.AutoMigrate(&Version{}); err != nil {
+		return nil, fmt.Errorf("migrating entity versions: %w", err)
+	}
+
+	return &Store{DB: db}, nil
+}
+
+// Next increments and returns the version of entity, starting at 1. The
+// increment is a single upsert, so concurrent publishers never share a version.
+func (s *Store) Next(ctx context.Context, entity string) (int64, error) {
+	v := Version{Entity: entity, Version: 1, UpdatedAt: time.Now()}
+
+	err := s.DB.WithContext(ctx).Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "entity"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"version":    gorm.Expr("entity_versions.version + 1"),
+				"updated_at": v.UpdatedAt,
+			}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "version"}}},
+	).Create(&v).Error
+
+	if err != nil {
+		return 0, fmt.Errorf("assigning a version to %s: %w", entity, err)
+	}
+
+	return v.Version, nil
+}
+
+// Current returns the last version handed out for entity, 0 if none was.
+func (s *Store) Current(ctx context.Context, entity string) (int64, error) {
+	var v Version
+
+	err := s.DB.WithContext(ctx).Where("entity = ?", entity).Limit(1).Find(&v).Error
+
+	return v.Version, err
+}